@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	sp "github.com/op/go-libspotify/spotify"
+)
+
+// coverPath returns the path of album's cover image, fetching it from
+// Spotify into the covers dir in the cache dir if it isn't there already.
+// It blocks while the image loads, so call it from a goroutine.
+func coverPath(album *sp.Album) (string, error) {
+	if album == nil {
+		return "", errors.New("No album")
+	}
+	link := album.Link()
+	if link == nil {
+		return "", errors.New("Album has no link")
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	// The id is the last part of the link, e.g. spotify:album:<id>
+	parts := strings.Split(link.String(), ":")
+	p := path.Join(dir, "covers", parts[len(parts)-1]+".jpg")
+	if _, err := os.Stat(p); err == nil {
+		return p, nil
+	}
+	image, err := album.Cover(sp.ImageSizeNormal)
+	if err != nil {
+		return "", err
+	}
+	if image == nil {
+		return "", errors.New("Album has no cover")
+	}
+	image.Wait()
+	data := image.Data()
+	if len(data) == 0 {
+		return "", errors.New("Cover didn't load")
+	}
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	// Write it under another name first, so a half written cover is never used
+	if err := ioutil.WriteFile(p+".part", data, 0644); err != nil {
+		return "", err
+	}
+	return p, os.Rename(p+".part", p)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -9,23 +10,71 @@ import (
 )
 
 var (
-	inputBufferSize = 16
+	// How much decoded audio AudioWriter holds between libspotify and the
+	// output device
+	bufferDuration = 2 * time.Second
+	// How much audio the device writer takes from the buffer at a time
+	chunkDuration = 50 * time.Millisecond
+	// How long to wait before retrying a failed device, at first and at most.
+	// The wait doubles with each failure in between.
+	retryMin = 250 * time.Millisecond
+	retryMax = 8 * time.Second
 )
 
-type audio struct {
-	format sp.AudioFormat
-	frames []byte
+// DeviceEvent is sent on AudioWriter.Events whenever the output device fails,
+// and again once it is working after a failure
+type DeviceEvent struct {
+	Err   error         // Why the device failed, or nil if it has recovered
+	Retry time.Duration // How long until the next attempt to use it
 }
 
+// DeviceStats describes the health of an AudioWriter's output device
+type DeviceStats struct {
+	Device  string
+	Failing bool  // No device is working at the moment
+	Errors  int   // Failed attempts to open or write to a device
+	LastErr error // The most recent of those failures
+}
+
+// BufferStats is a snapshot of how full an AudioWriter's buffer is
+type BufferStats struct {
+	Buffered time.Duration // Audio waiting to be played
+	Capacity time.Duration // Total audio the buffer can hold
+	Rejected int           // Deliveries from libspotify refused because the buffer was full
+}
+
+// Fill returns how full the buffer is, from 0 to 1
+func (s BufferStats) Fill() float64 {
+	if s.Capacity == 0 {
+		return 0
+	}
+	return float64(s.Buffered) / float64(s.Capacity)
+}
+
+// AudioWriter receives audio from libspotify, buffers it and writes it to an
+// audio device from its own goroutine. All state shared between the two is
+// guarded by mu; cond is broadcast whenever any of it changes.
 type AudioWriter struct {
-	input      chan audio
-	quit       chan bool
+	mu         sync.Mutex
+	cond       *sync.Cond
+	buf        *ringBuffer
+	format     sp.AudioFormat
+	paused     bool
+	closed     bool
+	rejected   int
 	wg         sync.WaitGroup
+	driver     int    // libao driver id of the output device
+	drivername string // and its short name
 	device     *audioDevice
+	flushes    int // Incremented by each Flush, so the writer can tell
+	failing    bool
+	errors     int
+	lasterr    error
 	timeplayed time.Duration
 	Ticks      chan time.Duration
-	flush      chan bool
-	paused     Latch
+	Events     chan DeviceEvent
+	DSP        *DSPChain // Applied to audio between the buffer and the device
+	Tap        *AudioTap // Sees audio after the DSP chain, as it is played
 }
 
 func AudioInit() {
@@ -38,60 +87,193 @@ func AudioDeinit() {
 
 // Pause allows instantaneous pause and unpause of buffer playback
 func (w *AudioWriter) Pause(pause bool) {
-	if pause {
-		w.paused.Set()
-	} else {
-		w.paused.Clear()
-	}
+	w.mu.Lock()
+	w.paused = pause
+	w.mu.Unlock()
+	w.cond.Broadcast()
 }
 
 // Flush unpauses and clear the current audio buffer
 func (w *AudioWriter) Flush() {
-	w.paused.Clear()
-	w.flush <- true
+	w.mu.Lock()
+	w.paused = false
+	if w.buf != nil {
+		w.buf.Reset()
+	}
+	w.flushes++
+	w.mu.Unlock()
+	w.cond.Broadcast()
 }
 
-func NewAudioWriter() (aw *AudioWriter, err error) {
+// Stats returns the current buffer fill metrics
+func (w *AudioWriter) Stats() BufferStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := BufferStats{Rejected: w.rejected}
+	if w.buf != nil {
+		stats.Buffered = bytesDuration(w.buf.Len(), w.format)
+		stats.Capacity = bytesDuration(w.buf.Cap(), w.format)
+	}
+	return stats
+}
+
+// NewAudioWriter starts an AudioWriter playing through the named libao driver,
+// or the system default if device is empty
+func NewAudioWriter(device string) (aw *AudioWriter, err error) {
 	aw = &AudioWriter{
-		input:  make(chan audio, inputBufferSize),
-		quit:   make(chan bool),
 		device: new(audioDevice),
 		Ticks:  make(chan time.Duration),
-		flush:  make(chan bool),
+		Events: make(chan DeviceEvent, 1),
+		DSP:    NewDSPChain(),
+		Tap:    NewAudioTap(4096),
 	}
-	driverid, err := ao.DefaultDriver()
+	aw.cond = sync.NewCond(&aw.mu)
+	driverid, err := lookupDriver(device)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	aw.setDriver(driverid)
 	aw.wg.Add(1)
-	go aw.AOWriter(driverid)
+	go aw.AOWriter()
 	return
 }
 
-func (w *AudioWriter) Close() {
-	w.Flush() // Flush and unpause first, to ensure the main AOWriter loop is unblocked
-	select {
-	case w.quit <- true:
-	default:
+// AudioDevices returns the short names of the libao drivers which can play
+// audio live, i.e. not to a file
+func AudioDevices() (names []string) {
+	for _, info := range ao.DriverInfoList() {
+		if info.Type == ao.TypeLive {
+			names = append(names, info.ShortName)
+		}
 	}
-	w.wg.Wait()
 	return
 }
 
-// The Libspotify callback for audio delivery
+// lookupDriver returns the libao driver id for a device name, where "" and
+// "default" mean the system default
+func lookupDriver(name string) (int, error) {
+	if name == "" || name == "default" {
+		return ao.DefaultDriver()
+	}
+	id, err := ao.DriverID(name)
+	if err != nil {
+		return 0, fmt.Errorf("No such audio device %q", name)
+	}
+	if info, err := ao.DriverInfo(id); err == nil && info.Type != ao.TypeLive {
+		return 0, fmt.Errorf("%q is not a live audio device", name)
+	}
+	return id, nil
+}
+
+// DeviceStats returns the health of the output device
+func (w *AudioWriter) DeviceStats() DeviceStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return DeviceStats{
+		Device:  w.drivername,
+		Failing: w.failing,
+		Errors:  w.errors,
+		LastErr: w.lasterr,
+	}
+}
+
+// Device returns the name of the device audio is being played on
+func (w *AudioWriter) Device() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.drivername
+}
+
+// SetDevice switches output to the named device. Buffered audio is kept, so
+// playback carries on from the same position on the new device.
+func (w *AudioWriter) SetDevice(name string) error {
+	id, err := lookupDriver(name)
+	if err != nil {
+		return err
+	}
+	w.setDriver(id)
+	audioLog.Infof("Switched to device %s", w.Device())
+	return nil
+}
+
+func (w *AudioWriter) setDriver(id int) {
+	name := fmt.Sprint(id)
+	if info, err := ao.DriverInfo(id); err == nil {
+		name = info.ShortName
+	}
+	w.mu.Lock()
+	w.driver = id
+	w.drivername = name
+	w.mu.Unlock()
+}
+
+// Close stops the device writer goroutine, discarding any buffered audio, and
+// waits for it to finish. Further audio from libspotify is refused.
+func (w *AudioWriter) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cond.Broadcast()
+	w.wg.Wait()
+}
+
+// The Libspotify callback for audio delivery. It buffers as many whole frames
+// as will fit and returns the number of bytes taken; anything less than
+// len(frames) tells libspotify to back off and deliver the rest later.
 func (w *AudioWriter) WriteAudio(format sp.AudioFormat, frames []byte) int {
-	select {
-	case w.input <- audio{format, frames}:
-		return len(frames)
-	default:
+	defer recoverCrash("libspotify audio")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0
+	}
+	if w.buf == nil || format != w.format {
+		if w.buf != nil && w.buf.Len() > 0 {
+			// Let audio in the old format drain before switching over
+			return 0
+		}
+		w.format = format
+		w.buf = newRingBuffer(durationBytes(bufferDuration, format))
+	}
+	n := w.buf.Free()
+	if n > len(frames) {
+		n = len(frames)
+	}
+	n -= n % frameSize(format)
+	if n == 0 {
+		w.rejected++
 		return 0
 	}
+	w.buf.Write(frames[:n])
+	w.cond.Broadcast()
+	return n
+}
+
+// frameSize returns the size in bytes of one frame (one 16 bit sample for
+// each channel) of audio in format
+func frameSize(format sp.AudioFormat) int {
+	return format.Channels * 2
+}
+
+// durationBytes returns the number of bytes of audio in format needed to
+// play for dur, rounded down to a whole frame
+func durationBytes(dur time.Duration, format sp.AudioFormat) int {
+	return int(dur*time.Duration(format.SampleRate)/time.Second) * frameSize(format)
+}
+
+// bytesDuration returns how long n bytes of audio in format takes to play
+func bytesDuration(n int, format sp.AudioFormat) time.Duration {
+	if format.SampleRate == 0 || format.Channels == 0 {
+		return 0
+	}
+	return time.Duration(n/frameSize(format)) * time.Second / time.Duration(format.SampleRate)
 }
 
 // AudioDevice wraps a portaudio device pointer with some state, allowing us to
 // handle changes in sample format neatly
 type audioDevice struct {
 	dev      *ao.Device
+	driver   int
 	channels int
 	rate     int
 }
@@ -115,18 +297,21 @@ func getSampleFormat(channels, rate int) *ao.SampleFormat {
 	}
 }
 
-// Ready the audiodevice for writing, with the given channel/rate configuration.
-// Reuses existing device when it can, opens new device when needed
+// Ready the audiodevice for writing, with the given channel/rate configuration
+// on the given driver. Reuses existing device when it can, opens new device
+// when needed
 func (a *audioDevice) Ready(channels, rate, driver int) (err error) {
-	if a.dev == nil || a.channels != channels || a.rate != rate {
+	if a.dev == nil || a.channels != channels || a.rate != rate || a.driver != driver {
 		if a.dev != nil {
 			//We have an open device; it just needs reconfiguring
 			a.dev.Close()
 		}
 		a.dev, err = ao.OpenLive(driver, getSampleFormat(channels, rate), nil)
 		if err != nil {
-			panic(err)
+			a.dev = nil
+			return
 		}
+		a.driver = driver
 		a.channels = channels
 		a.rate = rate
 	}
@@ -134,40 +319,143 @@ func (a *audioDevice) Ready(channels, rate, driver int) (err error) {
 }
 
 func (a *audioDevice) Close() {
+	if a.dev == nil {
+		return
+	}
 	a.dev.Close()
 	a.dev = nil
 }
 
-func (w *AudioWriter) AOWriter(driverid int) {
+// AOWriter is the device writer goroutine. It waits for buffered audio, and
+// writes it out a chunk at a time until the AudioWriter is closed. When no
+// device will take the audio it holds on to the chunk and retries, backing off
+// each time, so nothing is lost and the buffer fills up, holding libspotify
+// back until the device returns.
+func (w *AudioWriter) AOWriter() {
+	defer recoverCrash("audio")
 	defer w.device.Close()
 	defer w.wg.Done()
+	var chunk []byte
+	for {
+		w.mu.Lock()
+		for !w.closed && (w.paused || w.buf == nil || w.buf.Len() == 0) {
+			w.cond.Wait()
+		}
+		if w.closed {
+			w.mu.Unlock()
+			return
+		}
+		format, driver, flushes := w.format, w.driver, w.flushes
+		size := durationBytes(chunkDuration, format)
+		if cap(chunk) < size {
+			chunk = make([]byte, size)
+		}
+		n := w.buf.Read(chunk[:size])
+		w.mu.Unlock()
+
+		w.DSP.Process(chunk[:n], format)
+		w.Tap.write(chunk[:n], format)
+
+		bytes, err := w.play(chunk[:n], format, driver)
+		for backoff := retryMin; err != nil; backoff *= 2 {
+			if backoff > retryMax {
+				backoff = retryMax
+			}
+			w.sendEvent(DeviceEvent{Err: err, Retry: backoff})
+			if !w.sleep(backoff, flushes) {
+				break
+			}
+			w.mu.Lock()
+			driver = w.driver
+			w.mu.Unlock()
+			if bytes, err = w.play(chunk[:n], format, driver); err == nil {
+				w.sendEvent(DeviceEvent{})
+			}
+		}
+		if err != nil {
+			// Closed or flushed while the device was down
+			continue
+		}
+		w.timeplayed += bytesDuration(bytes, format)
+		if w.timeplayed > time.Duration(1)*time.Second {
+			// Nonblocking send - if we can't send, don't reset timeplayed and
+			// we'll try next time round.
+			select {
+			case w.Ticks <- w.timeplayed:
+				w.timeplayed = time.Duration(0)
+			default:
+			}
+		}
+	}
+}
+
+// play writes frames to the given driver's device, falling back to the
+// default device if that fails, and records the outcome in the device stats
+func (w *AudioWriter) play(frames []byte, format sp.AudioFormat, driver int) (int, error) {
+	n, err := w.write(frames, format, driver)
+	if err != nil {
+		// Fall back to the default device, which may have changed since
+		// we started, and give it a go there instead
+		if def, deferr := ao.DefaultDriver(); deferr == nil && def != driver {
+			audioLog.Warnf("Writing to driver %d failed: %s, falling back to the default", driver, err)
+			w.setDriver(def)
+			n, err = w.write(frames, format, def)
+		}
+	}
+	w.mu.Lock()
+	w.failing = err != nil
+	if err != nil {
+		w.errors++
+		w.lasterr = err
+	}
+	w.mu.Unlock()
+	return n, err
+}
+
+// sleep waits for d, returning early with false if the AudioWriter is closed
+// or flushed since flushes was read
+func (w *AudioWriter) sleep(d time.Duration, flushes int) bool {
+	timer := time.AfterFunc(d, func() {
+		w.mu.Lock()
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(d)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.closed && w.flushes == flushes && time.Now().Before(deadline) {
+		w.cond.Wait()
+	}
+	return !w.closed && w.flushes == flushes
+}
+
+// sendEvent sends ev on Events without blocking, discarding an unread older
+// event if need be; only the latest state of the device matters
+func (w *AudioWriter) sendEvent(ev DeviceEvent) {
 	for {
 		select {
-		case <-w.flush:
-			// Flush the input buffer (E.G. on song change) by remaking the channel.
-			w.input = make(chan audio, inputBufferSize)
-		case <-w.quit:
+		case w.Events <- ev:
 			return
-		case input := <-w.input:
-			// TODO: refresh the default driverid so we can 'roam' across devices.
-			w.device.Ready(input.format.Channels, input.format.SampleRate, driverid)
-			bytes, err := w.device.dev.Write(input.frames)
-			if err != nil && w.device.dev != nil {
-				// Close the device and hope it can be reopened next time round
-				w.device.Close()
-			} else {
-				w.timeplayed += time.Duration(((bytes/input.format.Channels/2)*1000000)/input.format.SampleRate) * time.Microsecond
-				if w.timeplayed > time.Duration(1)*time.Second {
-					// Nonblocking send - if we can't send, don't reset timeplayed and
-					// we'll try next time round.
-					select {
-					case w.Ticks <- w.timeplayed:
-						w.timeplayed = time.Duration(0)
-					default:
-					}
-				}
+		default:
+			select {
+			case <-w.Events:
+			default:
 			}
-			w.paused.Wait() // Block here if paused is set
 		}
 	}
 }
+
+// write plays frames on the given driver, opening or reconfiguring the device
+// as needed. On failure the device is closed, in the hope that it can be
+// reopened next time round.
+func (w *AudioWriter) write(frames []byte, format sp.AudioFormat, driver int) (int, error) {
+	if err := w.device.Ready(format.Channels, format.SampleRate, driver); err != nil {
+		return 0, err
+	}
+	n, err := w.device.dev.Write(frames)
+	if err != nil {
+		w.device.Close()
+	}
+	return n, err
+}
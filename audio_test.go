@@ -0,0 +1,142 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	sp "github.com/op/go-libspotify/spotify"
+)
+
+var testFormat = sp.AudioFormat{SampleType: sp.SampleTypeInt16NativeEndian, SampleRate: 44100, Channels: 2}
+
+var audioInitOnce sync.Once
+
+// newTestAudioWriter starts an AudioWriter playing to libao's null driver,
+// which throws the audio away
+func newTestAudioWriter(t *testing.T) *AudioWriter {
+	audioInitOnce.Do(AudioInit)
+	aw, err := NewAudioWriter("null")
+	if err != nil {
+		t.Skipf("No null audio device: %s", err)
+	}
+	return aw
+}
+
+func TestWriteAudioBackpressure(t *testing.T) {
+	aw := newTestAudioWriter(t)
+	defer aw.Close()
+	aw.Pause(true) // So nothing drains the buffer
+	size := durationBytes(bufferDuration, testFormat)
+	frames := make([]byte, size+1000)
+	if n := aw.WriteAudio(testFormat, frames); n != size {
+		t.Fatalf("WriteAudio into an empty buffer = %d, want its size %d", n, size)
+	}
+	if n := aw.WriteAudio(testFormat, frames); n != 0 {
+		t.Fatalf("WriteAudio into a full buffer = %d, want 0", n)
+	}
+	if stats := aw.Stats(); stats.Rejected != 1 || stats.Fill() != 1 {
+		t.Fatalf("Stats = %+v, want 1 rejected and a full buffer", stats)
+	}
+}
+
+func TestWriteAudioWholeFrames(t *testing.T) {
+	aw := newTestAudioWriter(t)
+	defer aw.Close()
+	aw.Pause(true)
+	// Frames are 4 bytes, so 7 bytes is one frame and part of another
+	if n := aw.WriteAudio(testFormat, make([]byte, 7)); n != 4 {
+		t.Fatalf("WriteAudio of 7 bytes = %d, want one 4 byte frame", n)
+	}
+	// Filling the buffer stops at the last whole frame too
+	free := durationBytes(bufferDuration, testFormat) - 4
+	if n := aw.WriteAudio(testFormat, make([]byte, free+2)); n != free {
+		t.Fatalf("WriteAudio of %d bytes with %d free = %d, want %d", free+2, free, n, free)
+	}
+}
+
+func TestWriteAudioFormatChange(t *testing.T) {
+	aw := newTestAudioWriter(t)
+	defer aw.Close()
+	aw.Pause(true)
+	aw.WriteAudio(testFormat, make([]byte, 400))
+	mono := testFormat
+	mono.Channels = 1
+	if n := aw.WriteAudio(mono, make([]byte, 400)); n != 0 {
+		t.Fatalf("WriteAudio in a new format with the old buffered = %d, want 0", n)
+	}
+	aw.Flush()
+	if n := aw.WriteAudio(mono, make([]byte, 400)); n != 400 {
+		t.Fatalf("WriteAudio in a new format after Flush = %d, want 400", n)
+	}
+}
+
+func TestWriteAudioAfterClose(t *testing.T) {
+	aw := newTestAudioWriter(t)
+	aw.Close()
+	if n := aw.WriteAudio(testFormat, make([]byte, 400)); n != 0 {
+		t.Fatalf("WriteAudio after Close = %d, want 0", n)
+	}
+}
+
+// TestAudioWriterConcurrent hammers an AudioWriter from several goroutines at
+// once, as libspotify and the UI do. Run it with -race.
+func TestAudioWriterConcurrent(t *testing.T) {
+	aw := newTestAudioWriter(t)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	loop := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				f(i)
+			}
+		}()
+	}
+	frames := make([]byte, durationBytes(10*time.Millisecond, testFormat))
+	loop(func(int) { aw.WriteAudio(testFormat, frames) })
+	loop(func(i int) {
+		if i%50 == 0 {
+			aw.Flush()
+		}
+		time.Sleep(time.Millisecond)
+	})
+	loop(func(i int) {
+		aw.Pause(i%2 == 0)
+		time.Sleep(time.Millisecond)
+	})
+	loop(func(int) {
+		aw.Stats()
+		aw.DeviceStats()
+	})
+	loop(func(int) {
+		select {
+		case <-aw.Ticks:
+		case <-aw.Events:
+		case <-time.After(time.Millisecond):
+		}
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		aw.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't return while audio was being written")
+	}
+	close(stop)
+	wg.Wait()
+	if n := aw.WriteAudio(testFormat, frames); n != 0 {
+		t.Fatalf("WriteAudio after Close = %d, want 0", n)
+	}
+}
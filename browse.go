@@ -0,0 +1,119 @@
+package main
+
+import (
+	tb "github.com/nsf/termbox-go"
+	sp "github.com/op/go-libspotify/spotify"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// SpotScreenBrowse lists the tracks of an album, or an artist's top tracks.
+// Browse screens are pushed on top of the screen they were opened from, and
+// Backspace or h goes back to it.
+type SpotScreenBrowse struct {
+	title  string
+	tracks *TrackList
+}
+
+func NewSpotScreenBrowse(title string, columns []string, tracks []*sp.Track) *SpotScreenBrowse {
+	s := &SpotScreenBrowse{title: title, tracks: NewTrackList(columns)}
+	for _, track := range tracks {
+		track.Wait()
+		s.tracks.AddTrack(track)
+	}
+	return s
+}
+
+// OpenAlbum browses album, and opens a screen listing its tracks
+func (g *Spot) OpenAlbum(album *sp.Album) string {
+	if album == nil {
+		return "No album"
+	}
+	browse := album.Browse()
+	browse.Wait()
+	if err := browse.Error(); err != nil {
+		return g.fail(err.Error())
+	}
+	tracks := make([]*sp.Track, browse.Tracks())
+	for i := range tracks {
+		tracks[i] = browse.Track(i)
+	}
+	g.Push(NewSpotScreenBrowse("Album: "+album.Name(), g.config.TrackColumns, tracks))
+	return ""
+}
+
+// OpenArtist browses artist, and opens a screen listing their top tracks
+func (g *Spot) OpenArtist(artist *sp.Artist) string {
+	if artist == nil {
+		return "No artist"
+	}
+	browse := artist.Browse(sp.ArtistBrowseNoAlbums)
+	browse.Wait()
+	if err := browse.Error(); err != nil {
+		return g.fail(err.Error())
+	}
+	tracks := make([]*sp.Track, browse.TopHitsTracks())
+	for i := range tracks {
+		tracks[i] = browse.TopHitsTrack(i)
+	}
+	if len(tracks) == 0 {
+		return "No top tracks for " + artist.Name()
+	}
+	g.Push(NewSpotScreenBrowse("Artist: "+artist.Name(), g.config.TrackColumns, tracks))
+	return ""
+}
+
+func (s *SpotScreenBrowse) Title() string {
+	return s.title
+}
+
+func (s *SpotScreenBrowse) Draw(x, y, w, h int) {
+	s.tracks.Draw(x, y, w, h, true)
+}
+
+func (s *SpotScreenBrowse) FocussedTracks() *TrackList {
+	return s.tracks
+}
+
+func (s *SpotScreenBrowse) SetFilter(query string) {
+	s.tracks.sl.SetFilter(query)
+}
+
+func (s *SpotScreenBrowse) Filter() string {
+	return s.tracks.sl.Filter()
+}
+
+func (s *SpotScreenBrowse) keys() []keybinding {
+	return []keybinding{
+		{keys: []tb.Key{tb.KeyEnter}, name: "enter", help: "Play the track, and queue the rest", run: func(*Spot) {
+			s.play()
+		}},
+		{ch: 'h', name: "h", help: "Go back, forward a screen", run: func(g *Spot) {
+			g.Back()
+		}},
+		{ch: 'l', name: "l", help: "Go back, forward a screen", run: func(g *Spot) {
+			g.Forward()
+		}},
+	}
+}
+
+func (s *SpotScreenBrowse) Keys() []ui.KeyHelp {
+	return append(keyHelp(s.keys()), s.tracks.Keys()...)
+}
+
+func (s *SpotScreenBrowse) HandleTBEvent(ev tb.Event) {
+	if ev.Type == tb.EventMouse {
+		if s.tracks.sl.HandleMouse(ev) == ui.MouseActivated {
+			s.play()
+		}
+		return
+	}
+	if !s.tracks.HandleKey(ev) {
+		spot.runKey(s.keys(), ev)
+	}
+}
+
+func (s *SpotScreenBrowse) play() {
+	if err := s.tracks.PlaySelected(); err != nil {
+		spot.fail(err.Error())
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	sp "github.com/op/go-libspotify/spotify"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// A command is run by typing one of its names after a colon, followed by its
+// arguments
+type command struct {
+	names []string // The first is shown in help, the rest are aliases
+	usage string   // The arguments it takes, for help
+	help  string
+	run   func(g *Spot, args []string) string // Returns a status message
+}
+
+// commands are all the commands docommand knows, in the order help lists
+// them. They're filled in by init, as some of them list the commands.
+var commands []command
+
+func init() {
+	commands = []command{
+		{[]string{"quit", "q"}, "", "Quit", func(g *Spot, _ []string) string {
+			g.quit = true
+			return ""
+		}},
+		{[]string{"help"}, "", "Show the keys and commands", func(g *Spot, _ []string) string {
+			g.ShowHelp()
+			return ""
+		}},
+		{[]string{"login"}, "<username> <password>", "Log in to Spotify", func(g *Spot, args []string) string {
+			if len(args) != 2 {
+				return "Usage: :login [username] [password]"
+			}
+			err := g.session.Login(sp.Credentials{
+				Username: args[0],
+				Password: args[1],
+			}, true)
+			if err != nil {
+				return g.fail("Login Error!")
+			}
+			return ""
+		}},
+		{[]string{"logout"}, "", "Log out, and choose whether to stay logged out", func(g *Spot, _ []string) string {
+			options := []string{"Log out, and forget my login", "Log out until next time", "Cancel"}
+			g.OpenDialog(ui.NewChoice("Log out", options, func(i int) {
+				if i < 0 || i == len(options)-1 {
+					g.status("Cancelled")
+					return
+				}
+				g.status(g.logout(i == 0))
+			}))
+			return ""
+		}},
+		{[]string{"relogin", "r"}, "", "Log in again as the last user", func(g *Spot, _ []string) string {
+			// TODO: Make this default somehow?
+			if err := g.session.Relogin(); err != nil {
+				return g.fail(err.Error())
+			}
+			return ""
+		}},
+		{[]string{"load", "l"}, "<link>", "Play the track at a spotify: link", func(g *Spot, args []string) string {
+			if !g.loggedin {
+				return "Login first!"
+			}
+			if len(args) != 1 {
+				return "Usage: :load <link>"
+			}
+			link, err := g.session.ParseLink(args[0])
+			if err != nil {
+				return g.fail(err.Error())
+			}
+			track, err := link.Track()
+			if err != nil {
+				return g.fail(err.Error())
+			}
+			track.Wait()
+			if err := g.Player.Load(track); err != nil {
+				return g.fail(err.Error())
+			}
+			return "Loaded!"
+		}},
+		{[]string{"eject", "e"}, "", "Stop playing and clear the queue", func(g *Spot, _ []string) string {
+			g.Player.Eject()
+			g.Player.queue = nil
+			return ""
+		}},
+		{[]string{"seek", "s"}, "<seconds>", "Go to a point in the track", func(g *Spot, args []string) string {
+			if len(args) != 1 {
+				return "Usage: seek <seconds>"
+			}
+			secs, err := strconv.Atoi(args[0])
+			if err != nil {
+				return "Enter a valid number of seconds"
+			}
+			g.Player.Seek(time.Duration(secs) * time.Second)
+			return ""
+		}},
+		{[]string{"enqueue", "queue"}, "", "Queue the marked or selected tracks", func(g *Spot, _ []string) string {
+			return g.enqueue()
+		}},
+		{[]string{"star"}, "", "Star or unstar the marked or selected tracks", func(g *Spot, _ []string) string {
+			return g.togglestar()
+		}},
+		{[]string{"copy", "yank"}, "", "Copy links to the marked or selected tracks", func(g *Spot, _ []string) string {
+			return g.copylinks()
+		}},
+		{[]string{"add"}, "<playlist>", "Add the marked or selected tracks to a playlist", func(g *Spot, args []string) string {
+			if len(args) == 0 {
+				return "Usage: :add <playlist>"
+			}
+			return g.addtoplaylist(strings.Join(args, " "))
+		}},
+		{[]string{"playlist", "pl"}, "new|folder|rename <name>, delete|up|down", "Organise playlists", func(g *Spot, args []string) string {
+			return g.playlistcommand(args)
+		}},
+		{[]string{"undo", "u"}, "", "Undo the last change to a playlist", func(g *Spot, _ []string) string {
+			return g.playlistsScreen().Undo()
+		}},
+		{[]string{"columns"}, "[column...]", "List, or set, the track list columns", func(g *Spot, args []string) string {
+			return g.columnscommand(args)
+		}},
+		{[]string{"sort"}, "<column> [desc], off", "Sort the track list", func(g *Spot, args []string) string {
+			if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "desc") {
+				return "Usage: :sort <column> [desc], or :sort off"
+			}
+			if args[0] == "off" {
+				args[0] = ""
+			}
+			tracks := g.focussedtracks()
+			if tracks == nil && g.currentscreen == SpotScreen(g.playlistsScreen()) {
+				tracks = g.playlistsScreen().tracksSL // The playlists have focus
+			}
+			if tracks == nil {
+				return "No track list to sort"
+			}
+			if err := tracks.SortBy(args[0], len(args) == 2); err != nil {
+				return g.fail(err.Error())
+			}
+			return ""
+		}},
+		{[]string{"album"}, "", "Open the selected track's album", func(g *Spot, _ []string) string {
+			tracks := g.targettracks()
+			if len(tracks) == 0 {
+				return "No track"
+			}
+			return g.OpenAlbum(tracks[0].Album())
+		}},
+		{[]string{"artist"}, "", "Open the selected track's artist", func(g *Spot, _ []string) string {
+			tracks := g.targettracks()
+			if len(tracks) == 0 || tracks[0].Artists() == 0 {
+				return "No artist"
+			}
+			return g.OpenArtist(tracks[0].Artist(0))
+		}},
+		{[]string{"back"}, "", "Go back a screen", func(g *Spot, _ []string) string {
+			g.Back()
+			return ""
+		}},
+		{[]string{"forward"}, "", "Go forward a screen", func(g *Spot, _ []string) string {
+			g.Forward()
+			return ""
+		}},
+		{[]string{"visualiser", "vis"}, "", "Show the visualiser", func(g *Spot, _ []string) string {
+			return g.ShowScreen(screenVis)
+		}},
+		{[]string{"nowplaying", "np"}, "", "Show what's playing, with its album cover", func(g *Spot, _ []string) string {
+			return g.ShowScreen(screenNowPlaying)
+		}},
+		{[]string{"lyrics"}, "", "Show the lyrics of what's playing", func(g *Spot, _ []string) string {
+			return g.ShowScreen(screenLyrics)
+		}},
+		{[]string{"messages"}, "", "Show the messages shown so far", func(g *Spot, _ []string) string {
+			g.messages.Dismiss()
+			return g.ShowScreen(screenMessages)
+		}},
+		{[]string{"diagnostics", "diag"}, "", "Show the audio diagnostics", func(g *Spot, _ []string) string {
+			return g.ShowScreen(screenDiag)
+		}},
+		{[]string{"eq"}, "[on|off|preset <name>|mono <on|off>|balance <-1..1>]", "Show, or change, the equaliser", func(g *Spot, args []string) string {
+			return g.eqcommand(args)
+		}},
+		{[]string{"theme"}, "[name]", "List the themes, or switch to one", func(g *Spot, args []string) string {
+			return g.themecommand(args)
+		}},
+		{[]string{"notify"}, "on|off", "Turn desktop notifications on or off", func(g *Spot, args []string) string {
+			return g.notifycommand(args)
+		}},
+		{[]string{"devices"}, "", "List the audio devices", func(g *Spot, _ []string) string {
+			devices := AudioDevices()
+			current := g.audiowriter.Device()
+			for i, d := range devices {
+				if d == current {
+					devices[i] = d + "*"
+				}
+			}
+			return "Devices: " + strings.Join(devices, ", ")
+		}},
+		{[]string{"device"}, "[name]", "Play through an audio device, or choose one", func(g *Spot, args []string) string {
+			if len(args) == 0 {
+				devices := AudioDevices()
+				g.OpenDialog(ui.NewChoice("Audio device", devices, func(i int) {
+					if i >= 0 {
+						g.status(g.docommand("device", devices[i:i+1]))
+					}
+				}))
+				return ""
+			}
+			if len(args) != 1 {
+				return "Usage: :device <name>, or :devices to list them"
+			}
+			if err := g.audiowriter.SetDevice(args[0]); err != nil {
+				return g.fail(err.Error())
+			}
+			return "Playing through " + g.audiowriter.Device()
+		}},
+	}
+}
+
+// findcommand returns the command called name, or nil if there isn't one
+func findcommand(name string) *command {
+	for i := range commands {
+		for _, n := range commands[i].names {
+			if n == name {
+				return &commands[i]
+			}
+		}
+	}
+	return nil
+}
+
+// docommand runs the command cmd and returns its status message
+func (g *Spot) docommand(cmd string, args []string) string {
+	uiLog.Debugf("Command %s", cmd) // Not its arguments, which may be a password
+	c := findcommand(cmd)
+	if c == nil {
+		g.warn("No such command: " + cmd)
+		return ""
+	}
+	return c.run(g, args)
+}
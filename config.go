@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// Config holds the user's settings which persist between runs of spot. It is
+// stored as JSON in the config dir.
+type Config struct {
+	EQ           DSPSettings  `json:"eq"`
+	TrackColumns []string     `json:"track_columns"`
+	Theme        string       `json:"theme"`
+	Notify       NotifyConfig `json:"notify"`
+	AlbumArt     string       `json:"album_art"` // auto, blocks, kitty, sixel or off
+	Lyrics       LyricsConfig `json:"lyrics"`
+}
+
+// DefaultConfig returns the config used when there is no config file yet
+func DefaultConfig() Config {
+	return Config{
+		EQ:           DefaultDSPSettings(),
+		TrackColumns: DefaultTrackColumns,
+		Theme:        ui.DarkTheme.Name,
+		Notify:       DefaultNotifyConfig(),
+		AlbumArt:     "auto",
+	}
+}
+
+// ConfigDir returns the directory spot keeps its config in, honouring
+// $XDG_CONFIG_HOME
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return path.Join(dir, "spot"), nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".config/spot"), nil
+}
+
+// SettingsDir returns the directory libspotify keeps its settings in,
+// including the saved login. It's ~/.cache/spot whatever $XDG_CACHE_HOME is,
+// as that's where spot has always told libspotify to keep them.
+func SettingsDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".cache/spot"), nil
+}
+
+// CacheDir returns the directory spot keeps libspotify's cache, and its logs,
+// in, honouring $XDG_CACHE_HOME
+func CacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return path.Join(dir, "spot"), nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".cache/spot"), nil
+}
+
+func configPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "config.json"), nil
+}
+
+// LoadConfig reads the config file. A missing file is not an error; the
+// default config is returned instead.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+	p, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// Save writes the config file, creating the config dir if needed
+func (c Config) Save() error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0644)
+}
+
+// LoadThemes adds the user's themes, from the themes dir in the config dir,
+// to ui.Themes. Each theme is named after its file, less the .json. Themes
+// that fail to load are skipped, and the last error returned.
+func LoadThemes() error {
+	dir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(path.Join(dir, "themes", "*.json"))
+	if err != nil {
+		return err
+	}
+	var lasterr error
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			lasterr = err
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(file), ".json")
+		theme, err := ui.ParseTheme(name, data)
+		if err != nil {
+			lasterr = fmt.Errorf("Theme %s: %v", name, err)
+			continue
+		}
+		ui.Themes[name] = theme
+	}
+	return lasterr
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+var playerStateNames = map[PlayerState]string{
+	Stopped: "stopped",
+	Playing: "playing",
+	Paused:  "paused",
+	Ejected: "ejected",
+}
+
+var closeTerminalOnce sync.Once
+
+// closeTerminal hands the terminal back from termbox, restoring it to how it
+// was. It's safe to call more than once, and from any goroutine.
+func closeTerminal() {
+	closeTerminalOnce.Do(func() {
+		if tb.IsInit {
+			stopFocusReporting()
+			tb.Close()
+		}
+	})
+}
+
+// fatal restores the terminal, logs v, and exits. Use it instead of
+// log.Fatal once termbox is running, as log.Fatal skips deferred calls and
+// would leave the terminal in raw mode.
+func fatal(v ...interface{}) {
+	closeTerminal()
+	logger.Log(LogError, "main", "%s", fmt.Sprint(v...))
+	logger.Close()
+	log.Fatalln(v...)
+}
+
+// recoverCrash is deferred at the top of main and of each of spot's
+// goroutines. If the goroutine is panicking, it restores the terminal,
+// writes a crash report to the cache dir, says where, and exits.
+func recoverCrash(goroutine string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	crash(goroutine, r, debug.Stack())
+}
+
+var crashOnce sync.Once
+
+// crash handles a panic in goroutine. Should several goroutines panic at
+// once, the first writes the report and the rest wait for it to exit.
+func crash(goroutine string, r interface{}, stack []byte) {
+	crashOnce.Do(func() {
+		closeTerminal()
+		logger.Log(LogError, "crash", "Panic in the %s goroutine: %v", goroutine, r)
+		fmt.Fprintf(os.Stderr, "Spot crashed: %v\n", r)
+		p, err := writeCrashReport(goroutine, r, stack)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't write a crash report (%s), so here's the stack:\n\n%s", err, stack)
+		} else {
+			fmt.Fprintf(os.Stderr, "A crash report has been written to %s\n", p)
+		}
+		logger.Close()
+		os.Exit(2)
+	})
+}
+
+// writeCrashReport writes a report of a panic to a new file in the cache dir,
+// returning its path
+func writeCrashReport(goroutine string, r interface{}, stack []byte) (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	p := path.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "Spot %s crashed at %s\n", version, now.Format(time.RFC3339))
+	fmt.Fprintf(f, "%s %s/%s\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(f, "Panic in the %s goroutine: %v\n\n%s\n", goroutine, r, stack)
+	fmt.Fprintf(f, "State:\n%s\n", spot.crashState())
+	fmt.Fprintf(f, "Recent log:\n")
+	if logger == nil {
+		fmt.Fprintf(f, "(logging is off)\n")
+	}
+	for _, line := range logger.Recent() {
+		fmt.Fprint(f, line)
+	}
+	fmt.Fprintf(f, "\nAll goroutines:\n%s\n", allStacks())
+	return p, f.Close()
+}
+
+// crashState describes what spot was up to, for a crash report. Whatever went
+// wrong may have left things in a bad way, so it recovers from panics too.
+func (g *Spot) crashState() (state string) {
+	var b strings.Builder
+	defer func() {
+		if r := recover(); r != nil {
+			state = b.String() + fmt.Sprintf("(panicked getting the rest: %v)\n", r)
+		}
+	}()
+	fmt.Fprintf(&b, "Logged in: %t\n", g.loggedin)
+	if g.currentscreen != nil {
+		fmt.Fprintf(&b, "Screen: %s\n", g.ScreenTitle(g.currentscreen))
+	}
+	if g.messages != nil {
+		if m := g.messages.Current(time.Now()); m != nil {
+			fmt.Fprintf(&b, "Message: %s\n", m.Text)
+		}
+	}
+	p := g.Player
+	if p == nil {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Player: %s, %s in, %d queued\n", playerStateNames[p.playstate], p.elapsed, len(p.queue))
+	if p.track != nil {
+		fmt.Fprintf(&b, "Track: %s - %s\n", p.track.Name(), ArtistNames(p.track))
+	}
+	return b.String()
+}
+
+// allStacks returns the stacks of every goroutine
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
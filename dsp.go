@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	sp "github.com/op/go-libspotify/spotify"
+)
+
+// Centre frequencies in Hz of the equaliser's bands
+var EQBands = []float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+const (
+	eqMaxGain  = 12.0 // Largest boost or cut, in dB, of any band or shelf
+	eqQ        = 1.41 // Bandwidth of the peaking filters, roughly one octave
+	bassFreq   = 100.0
+	trebleFreq = 10000.0
+)
+
+// Gains in dB for each of EQBands, by preset name
+var EQPresets = map[string][]float64{
+	"flat":      {0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	"rock":      {5, 4, 3, 1, -1, -1, 1, 3, 4, 5},
+	"pop":       {-1, 1, 3, 4, 3, 0, -1, -1, 1, 2},
+	"jazz":      {3, 2, 1, 2, -1, -1, 0, 1, 2, 3},
+	"classical": {4, 3, 2, 1, -1, -1, 0, 2, 3, 4},
+	"bass":      {7, 6, 5, 3, 1, 0, 0, 0, 0, 0},
+	"treble":    {0, 0, 0, 0, 0, 1, 3, 5, 6, 7},
+	"vocal":     {-2, -2, -1, 1, 3, 4, 3, 1, 0, -1},
+}
+
+// EQPresetNames returns the names of EQPresets in alphabetical order
+func EQPresetNames() []string {
+	var names []string
+	for name := range EQPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DSPSettings describes everything the DSP chain does to the audio. Gains are
+// in dB. Preset is "custom" once any band has been changed by hand.
+type DSPSettings struct {
+	Enabled bool      `json:"enabled"`
+	Preset  string    `json:"preset"`
+	Bands   []float64 `json:"bands"`
+	Bass    float64   `json:"bass"`
+	Treble  float64   `json:"treble"`
+	Balance float64   `json:"balance"` // -1 is full left, 1 full right
+	Mono    bool      `json:"mono"`
+}
+
+// DefaultDSPSettings returns settings which leave the audio untouched
+func DefaultDSPSettings() DSPSettings {
+	return DSPSettings{
+		Preset: "flat",
+		Bands:  append([]float64(nil), EQPresets["flat"]...),
+	}
+}
+
+// biquad is a second order IIR filter section, with its own state for a
+// single channel
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// setCoefficients gives f g's coefficients, keeping f's state, so the audio
+// carries on smoothly through the change
+func (f *biquad) setCoefficients(g biquad) {
+	f.b0, f.b1, f.b2, f.a1, f.a2 = g.b0, g.b1, g.b2, g.a1, g.a2
+}
+
+// passThrough is a filter which leaves the audio as it is
+var passThrough = biquad{b0: 1}
+
+// newBiquad returns a filter with the given coefficients, normalised by a0
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// The filter designs below are from Robert Bristow-Johnson's Audio EQ Cookbook
+
+func peakingFilter(freq, gain, rate float64) biquad {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w0) / (2 * eqQ)
+	cos := math.Cos(w0)
+	return newBiquad(1+alpha*a, -2*cos, 1-alpha*a, 1+alpha/a, -2*cos, 1-alpha/a)
+}
+
+func shelfFilter(freq, gain, rate float64, high bool) biquad {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * freq / rate
+	cos := math.Cos(w0)
+	// 2*sqrt(A)*alpha, with the shelf slope fixed at 1
+	beta := math.Sqrt(2*a) * math.Sin(w0)
+	if high {
+		return newBiquad(
+			a*((a+1)+(a-1)*cos+beta),
+			-2*a*((a-1)+(a+1)*cos),
+			a*((a+1)+(a-1)*cos-beta),
+			(a+1)-(a-1)*cos+beta,
+			2*((a-1)-(a+1)*cos),
+			(a+1)-(a-1)*cos-beta)
+	}
+	return newBiquad(
+		a*((a+1)-(a-1)*cos+beta),
+		2*a*((a-1)-(a+1)*cos),
+		a*((a+1)-(a-1)*cos-beta),
+		(a+1)+(a-1)*cos+beta,
+		-2*((a-1)+(a+1)*cos),
+		(a+1)+(a-1)*cos-beta)
+}
+
+// DSPChain applies the equaliser, tone shelves, mono downmix and balance, in
+// that order, to audio on its way to the output device. It is safe to change
+// the settings from one goroutine while another is processing audio.
+type DSPChain struct {
+	mu       sync.Mutex
+	settings DSPSettings
+	filters  [][]biquad // Filter stages for each channel
+	format   sp.AudioFormat
+	dirty    bool      // Filters need redesigning before the next Process
+	scratch  []float64 // Reused by Process for a frame's samples and the channels' gains
+}
+
+func NewDSPChain() *DSPChain {
+	return &DSPChain{settings: DefaultDSPSettings(), dirty: true}
+}
+
+// Settings returns a copy of the chain's current settings
+func (c *DSPChain) Settings() DSPSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.settings
+	s.Bands = append([]float64(nil), s.Bands...)
+	return s
+}
+
+// SetSettings replaces all of the chain's settings at once, e.g. when loading
+// them from the config file. Out of range values are clamped.
+func (c *DSPChain) SetSettings(s DSPSettings) {
+	bands := make([]float64, len(EQBands))
+	copy(bands, s.Bands)
+	for i := range bands {
+		bands[i] = clampGain(bands[i])
+	}
+	s.Bands = bands
+	s.Bass = clampGain(s.Bass)
+	s.Treble = clampGain(s.Treble)
+	s.Balance = math.Max(-1, math.Min(1, s.Balance))
+	c.update(func(cur *DSPSettings) { *cur = s })
+}
+
+// SetPreset loads the named preset's band gains and enables the equaliser
+func (c *DSPChain) SetPreset(name string) error {
+	gains, ok := EQPresets[name]
+	if !ok {
+		return fmt.Errorf("No such preset %q", name)
+	}
+	c.update(func(s *DSPSettings) {
+		s.Preset = name
+		s.Bands = append([]float64(nil), gains...)
+		s.Enabled = true
+	})
+	return nil
+}
+
+// AdjustBand changes the gain of band i by delta dB
+func (c *DSPChain) AdjustBand(i int, delta float64) {
+	c.update(func(s *DSPSettings) {
+		s.Bands[i] = clampGain(s.Bands[i] + delta)
+		s.Preset = "custom"
+	})
+}
+
+// AdjustShelf changes the gain of the treble shelf if treble is true, or the
+// bass shelf otherwise, by delta dB
+func (c *DSPChain) AdjustShelf(treble bool, delta float64) {
+	c.update(func(s *DSPSettings) {
+		if treble {
+			s.Treble = clampGain(s.Treble + delta)
+		} else {
+			s.Bass = clampGain(s.Bass + delta)
+		}
+	})
+}
+
+// SetBalance sets the channel balance, from -1 (left) to 1 (right)
+func (c *DSPChain) SetBalance(balance float64) {
+	c.update(func(s *DSPSettings) {
+		s.Balance = math.Max(-1, math.Min(1, balance))
+	})
+}
+
+func (c *DSPChain) SetMono(mono bool) {
+	c.update(func(s *DSPSettings) { s.Mono = mono })
+}
+
+func (c *DSPChain) SetEnabled(enabled bool) {
+	c.update(func(s *DSPSettings) { s.Enabled = enabled })
+}
+
+func (c *DSPChain) update(f func(*DSPSettings)) {
+	c.mu.Lock()
+	f(&c.settings)
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+func clampGain(gain float64) float64 {
+	return math.Max(-eqMaxGain, math.Min(eqMaxGain, gain))
+}
+
+// design works out the filters for the current settings and format. There's
+// a stage for every band and shelf, which passes the audio through when it
+// has nothing to do, so when only the settings have changed the filters keep
+// their state and moving a slider doesn't click. Called with mu held.
+func (c *DSPChain) design() {
+	rate := float64(c.format.SampleRate)
+	stages := make([]biquad, 0, len(EQBands)+2)
+	for i, gain := range c.settings.Bands {
+		f := passThrough
+		// Bands at or above the Nyquist frequency can't be represented
+		if gain != 0 && EQBands[i] < rate/2 {
+			f = peakingFilter(EQBands[i], gain, rate)
+		}
+		stages = append(stages, f)
+	}
+	bass, treble := passThrough, passThrough
+	if c.settings.Bass != 0 {
+		bass = shelfFilter(bassFreq, c.settings.Bass, rate, false)
+	}
+	if c.settings.Treble != 0 && trebleFreq < rate/2 {
+		treble = shelfFilter(trebleFreq, c.settings.Treble, rate, true)
+	}
+	stages = append(stages, bass, treble)
+	if len(c.filters) == c.format.Channels {
+		for ch := range c.filters {
+			for i := range stages {
+				c.filters[ch][i].setCoefficients(stages[i])
+			}
+		}
+	} else {
+		c.filters = make([][]biquad, c.format.Channels)
+		for ch := range c.filters {
+			c.filters[ch] = append([]biquad(nil), stages...)
+		}
+	}
+	c.dirty = false
+}
+
+// Process applies the chain in place to frames, which holds interleaved
+// native endian 16 bit samples in format
+func (c *DSPChain) Process(frames []byte, format sp.AudioFormat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.settings.Enabled || format.Channels == 0 {
+		c.filters = nil // Their state will be stale when the chain is next enabled
+		return
+	}
+	if format != c.format {
+		// The old state is no use at a different rate
+		c.format, c.filters = format, nil
+		c.design()
+	} else if c.dirty {
+		c.design()
+	}
+	channels := format.Channels
+	if len(c.scratch) < 2*channels {
+		c.scratch = make([]float64, 2*channels)
+	}
+	frame, gains := c.scratch[:channels], c.scratch[channels:2*channels]
+	for ch := range gains {
+		gains[ch] = 1
+	}
+	if channels == 2 {
+		gains[0] = math.Min(1, 1-c.settings.Balance)
+		gains[1] = math.Min(1, 1+c.settings.Balance)
+	}
+	for i := 0; i+channels*2 <= len(frames); i += channels * 2 {
+		for ch := range frame {
+			frame[ch] = float64(int16(binary.NativeEndian.Uint16(frames[i+ch*2:])))
+			for f := range c.filters[ch] {
+				frame[ch] = c.filters[ch][f].process(frame[ch])
+			}
+		}
+		if c.settings.Mono && channels > 1 {
+			var sum float64
+			for _, s := range frame {
+				sum += s
+			}
+			for ch := range frame {
+				frame[ch] = sum / float64(channels)
+			}
+		}
+		for ch, s := range frame {
+			s = math.Max(math.MinInt16, math.Min(math.MaxInt16, s*gains[ch]))
+			binary.NativeEndian.PutUint16(frames[i+ch*2:], uint16(int16(s)))
+		}
+	}
+}
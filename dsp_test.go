@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sine returns n frames of a 1kHz tone in testFormat
+func sine(n, from int) []byte {
+	frames := make([]byte, n*4)
+	for i := 0; i < n; i++ {
+		s := int16(8000 * math.Sin(2*math.Pi*1000*float64(from+i)/44100))
+		binary.NativeEndian.PutUint16(frames[i*4:], uint16(s))
+		binary.NativeEndian.PutUint16(frames[i*4+2:], uint16(s))
+	}
+	return frames
+}
+
+func TestProcessDoesNotAllocate(t *testing.T) {
+	c := NewDSPChain()
+	c.SetPreset("rock")
+	frames := sine(1024, 0)
+	c.Process(frames, testFormat)
+	if allocs := testing.AllocsPerRun(100, func() { c.Process(frames, testFormat) }); allocs != 0 {
+		t.Errorf("Process allocated %.0f times per chunk, want none", allocs)
+	}
+}
+
+func TestAdjustKeepsFilterState(t *testing.T) {
+	c := NewDSPChain()
+	c.SetPreset("rock")
+	c.Process(sine(1024, 0), testFormat)
+	before := c.filters[0][5]
+	c.AdjustBand(5, 1)
+	c.AdjustBand(0, -5) // Switches a stage off
+	c.Process(nil, testFormat)
+	after := c.filters[0][5]
+	if after.x1 != before.x1 || after.y1 != before.y1 || after.y2 != before.y2 {
+		t.Errorf("Adjusting a band reset its state from %+v to %+v", before, after)
+	}
+	if after.b0 == before.b0 {
+		t.Error("Adjusting a band didn't change its coefficients")
+	}
+	if off := c.filters[0][0]; off.b0 != 1 || off.b1 != 0 || off.b2 != 0 || off.a1 != 0 || off.a2 != 0 {
+		t.Errorf("Band at 0dB isn't passed through: %+v", off)
+	}
+}
+
+func TestFormatChangeResetsFilters(t *testing.T) {
+	c := NewDSPChain()
+	c.SetPreset("rock")
+	c.Process(sine(1024, 0), testFormat)
+	mono := testFormat
+	mono.Channels = 1
+	c.Process(make([]byte, 2), mono)
+	if len(c.filters) != 1 || c.filters[0][0].y2 != 0 {
+		t.Errorf("Filters after changing format = %+v, want fresh ones for one channel", c.filters)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft computes the discrete Fourier transform of x in place, using the
+// iterative radix-2 Cooley-Tukey algorithm. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	// Reorder into bit-reversed index order
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// spectrum returns the magnitude of each frequency bin of samples, after
+// applying a Hann window, normalised so a full scale sine wave is 1. Only the
+// len(samples)/2 bins up to the Nyquist frequency are returned.
+func spectrum(samples []float64) []float64 {
+	n := len(samples)
+	x := make([]complex128, n)
+	for i, s := range samples {
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		x[i] = complex(s*window, 0)
+	}
+	fft(x)
+	mags := make([]float64, n/2)
+	for i := range mags {
+		// The Hann window halves the amplitude, and the energy of a real
+		// signal is split between positive and negative frequencies
+		mags[i] = cmplx.Abs(x[i]) * 4 / float64(n)
+	}
+	return mags
+}
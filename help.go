@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// SpotHelp lists the keys for the screen it was opened over, the global keys
+// and the commands, in a box on top of the screen. It's made fresh each time
+// it's opened, from the keymap and command table, so it's never out of date.
+type SpotHelp struct {
+	sl ui.ScrollList
+}
+
+// Widest the help box gets, so lines don't stretch right across wide terminals
+const helpMaxWidth = 90
+
+// NewSpotHelp returns help for the keys of screen, along with everything else
+func NewSpotHelp(screen SpotScreen) *SpotHelp {
+	h := &SpotHelp{sl: ui.NewScrollList()}
+	if s, ok := screen.(HelpfulScreen); ok {
+		h.addSection(spot.ScreenTitle(screen), s.Keys())
+	}
+	h.addSection("Everywhere", keyHelp(globalKeys))
+	var cmds []ui.KeyHelp
+	for _, c := range commands {
+		usage := ":" + c.names[0]
+		if c.usage != "" {
+			usage += " " + c.usage
+		}
+		help := c.help
+		if len(c.names) > 1 {
+			help += " (also :" + strings.Join(c.names[1:], ", :") + ")"
+		}
+		cmds = append(cmds, ui.KeyHelp{Keys: usage, Help: help})
+	}
+	h.addSection("Commands", cmds)
+	h.sl.SelectTop()
+	return h
+}
+
+// addSection adds a heading, then a line for each of keys with the help
+// lined up after the widest key, except for keys too wide to line up with
+func (s *SpotHelp) addSection(heading string, keys []ui.KeyHelp) {
+	if len(s.sl.Items) > 0 {
+		s.sl.Items = append(s.sl.Items, ui.ListItem{Disabled: true})
+	}
+	s.sl.Items = append(s.sl.Items, ui.ListItem{TextL: heading, Disabled: true})
+	width := 0
+	for _, k := range keys {
+		if w := ui.StringWidth(k.Keys); w > width && w <= 24 {
+			width = w
+		}
+	}
+	for _, k := range keys {
+		pad := width - ui.StringWidth(k.Keys)
+		if pad < 0 {
+			pad = 0
+		}
+		s.sl.Items = append(s.sl.Items, ui.ListItem{TextL: "  " + k.Keys + strings.Repeat(" ", pad+2) + k.Help})
+	}
+	s.sl.SetItems(s.sl.Items)
+}
+
+// ShowHelp opens help over the current screen
+func (g *Spot) ShowHelp() {
+	g.help = NewSpotHelp(g.currentscreen)
+}
+
+// activescreen returns help if it's open, otherwise the current screen
+func (g *Spot) activescreen() SpotScreen {
+	if g.help != nil {
+		return g.help
+	}
+	return g.currentscreen
+}
+
+func (s *SpotHelp) Draw(x, y, w, h int) {
+	r := ui.Rect{X: x + 2, Y: y + 1, W: w - 4, H: h - 2}
+	if r.W > helpMaxWidth {
+		r.X += (r.W - helpMaxWidth) / 2
+		r.W = helpMaxWidth
+	}
+	if r.W < 10 || r.H < 3 {
+		ui.DrawTooSmall(ui.Rect{X: x, Y: y, W: w, H: h})
+		return
+	}
+	for row := r.Y; row < r.Y+r.H; row++ {
+		ui.Fill(r.X, row, r.W, ui.StyleOf(ui.RoleNormal))
+	}
+	ui.Drawbox(r.X, r.Y, r.W, r.H, "Help")
+	hint := ui.StyleOf(ui.RoleDim)
+	ui.Printr(r.X+r.W-2, r.Y, hint.Fg, hint.Bg, " / filter  esc close ")
+	s.sl.Draw(r.X+2, r.Y+1, r.W-4, r.H-2, true)
+}
+
+func (s *SpotHelp) SetFilter(query string) {
+	s.sl.SetFilter(query)
+}
+
+func (s *SpotHelp) Filter() string {
+	return s.sl.Filter()
+}
+
+// HandleTBEvent scrolls the help. Esc clears the filter, or closes help if
+// there isn't one, as do q and ?.
+func (s *SpotHelp) HandleTBEvent(ev tb.Event) {
+	if ev.Type == tb.EventMouse {
+		s.sl.HandleMouse(ev)
+		return
+	}
+	if s.sl.HandleKey(ev) {
+		return
+	}
+	switch {
+	case ev.Key == tb.KeyEsc && s.sl.Filter() != "":
+		s.sl.SetFilter("")
+	case ev.Key == tb.KeyEsc, ev.Ch == 'q', ev.Ch == '?':
+		spot.help = nil
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// A keybinding is a key, and what it does in normal mode. globalKeys do the
+// same thing on every screen, and each screen has its own for the rest. Help
+// is made from the same bindings the keys are run from.
+type keybinding struct {
+	keys []tb.Key // Special keys, such as tb.KeyF1
+	ch   rune     // And a character
+	name string   // How help shows the key
+	help string
+	run  func(g *Spot)
+}
+
+// matches returns whether ev is a press of b's key
+func (b *keybinding) matches(ev tb.Event) bool {
+	if ev.Ch != 0 {
+		return ev.Ch == b.ch
+	}
+	for _, key := range b.keys {
+		if ev.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// globalKeys are the keys handled before the current screen gets a look in.
+// They're filled in by init, as ? shows help which lists them.
+var globalKeys []keybinding
+
+func init() {
+	globalKeys = []keybinding{
+		{ch: ':', name: ":", help: "Enter a command", run: func(g *Spot) {
+			g.StartCommand("")
+		}},
+		{ch: '/', name: "/", help: "Filter the list", run: func(g *Spot) {
+			if screen, ok := g.activescreen().(FilterableScreen); ok {
+				// Carry on from any filter already in place
+				g.mode = Search
+				g.messages.Dismiss()
+				g.cmdline.Text = []rune("/" + screen.Filter())
+			}
+		}},
+		{keys: []tb.Key{tb.KeyEsc}, name: "esc", help: "Clear the filter, and dismiss the message", run: func(g *Spot) {
+			g.messages.Dismiss()
+			if screen, ok := g.activescreen().(FilterableScreen); ok {
+				screen.SetFilter("")
+			}
+		}},
+		{ch: '?', name: "?", help: "Show this help", run: func(g *Spot) {
+			g.ShowHelp()
+		}},
+		{ch: 'q', name: "q", help: "Quit", run: func(g *Spot) {
+			g.quit = true
+		}},
+		{ch: 'c', name: "c", help: "Play or pause", run: func(g *Spot) {
+			g.Player.PlayPause()
+		}},
+		{ch: 'v', name: "v", help: "Stop", run: func(g *Spot) {
+			g.Player.Stop()
+		}},
+		{keys: []tb.Key{tb.KeyArrowLeft}, name: "←", help: "Seek back 10 seconds", run: func(g *Spot) {
+			g.Player.Scrub(time.Duration(-10) * time.Second)
+		}},
+		{keys: []tb.Key{tb.KeyArrowRight}, name: "→", help: "Seek forward 10 seconds", run: func(g *Spot) {
+			g.Player.Scrub(time.Duration(10) * time.Second)
+		}},
+		{ch: '*', name: "*", help: "Star or unstar the marked or selected tracks", run: func(g *Spot) {
+			g.status(g.togglestar())
+		}},
+		{keys: []tb.Key{tb.KeyBackspace, tb.KeyBackspace2}, name: "backspace", help: "Go back a screen", run: func(g *Spot) {
+			g.Back()
+		}},
+		{ch: '[', name: "[", help: "Go back a screen", run: func(g *Spot) {
+			g.Back()
+		}},
+		{ch: ']', name: "]", help: "Go forward a screen", run: func(g *Spot) {
+			g.Forward()
+		}},
+		// The screens were on 0, 1 and 2 until lists took digits as counts,
+		// as in 5j, so they're on function keys instead
+		{keys: []tb.Key{tb.KeyF1}, name: "F1", help: "Show the about screen", run: func(g *Spot) {
+			g.ShowScreen(screenAbout)
+		}},
+		{keys: []tb.Key{tb.KeyF2}, name: "F2", help: "Show the playlists", run: func(g *Spot) {
+			g.status(g.showplaylists())
+		}},
+		{keys: []tb.Key{tb.KeyF3}, name: "F3", help: "Show the visualiser", run: func(g *Spot) {
+			g.ShowScreen(screenVis)
+		}},
+		{keys: []tb.Key{tb.KeyF4}, name: "F4", help: "Show what's playing, with its album cover", run: func(g *Spot) {
+			g.ShowScreen(screenNowPlaying)
+		}},
+		{keys: []tb.Key{tb.KeyF5}, name: "F5", help: "Show the lyrics of what's playing", run: func(g *Spot) {
+			g.ShowScreen(screenLyrics)
+		}},
+	}
+}
+
+// keyHelp returns the help for bindings, with keys next to each other which
+// do the same thing listed together
+func keyHelp(bindings []keybinding) (help []ui.KeyHelp) {
+	for _, b := range bindings {
+		help = ui.AddKeyHelp(help, b.name, b.help)
+	}
+	return
+}
+
+// runKey runs the binding in bindings which ev is a press of, returning
+// whether there was one
+func (g *Spot) runKey(bindings []keybinding, ev tb.Event) bool {
+	for i := range bindings {
+		if bindings[i].matches(ev) {
+			bindings[i].run(g)
+			return true
+		}
+	}
+	return false
+}
+
+// handleKey handles a keypress in whichever mode the cmdline is in
+func (g *Spot) handleKey(ev tb.Event) {
+	switch g.mode {
+	case Command:
+		g.handleCommandKey(ev)
+	case Search:
+		g.handleSearchKey(ev)
+	default:
+		g.handleNormalKey(ev)
+	}
+}
+
+// handleCommandKey edits the command being typed, and runs it on Enter
+func (g *Spot) handleCommandKey(ev tb.Event) {
+	switch ev.Key {
+	case tb.KeyEnter:
+		g.mode = Normal
+		if len(g.cmdline.Text) > 1 {
+			banana := strings.Split(string(g.cmdline.Text[1:]), " ")
+			g.status(g.docommand(banana[0], banana[1:]))
+			g.cmdline.Push()
+		}
+	case tb.KeyBackspace, tb.KeyBackspace2:
+		g.cmdline.DelChar()
+	case tb.KeyDelete:
+		// TODO: this, requires a cursor
+	case tb.KeySpace:
+		g.cmdline.AddChar(' ')
+	case tb.KeyEsc:
+		g.cmdline.Clear()
+		g.mode = Normal
+	default:
+		if ev.Ch != 0 {
+			g.cmdline.AddChar(ev.Ch)
+		}
+	}
+}
+
+// handleSearchKey edits the filter being typed, filtering as it goes
+func (g *Spot) handleSearchKey(ev tb.Event) {
+	switch ev.Key {
+	case tb.KeyEnter: // Keep the filter and go back to the list
+		g.mode = Normal
+		g.cmdline.Clear()
+		return
+	case tb.KeyBackspace, tb.KeyBackspace2:
+		g.cmdline.DelChar()
+		if len(g.cmdline.Text) == 0 { // Deleted the /
+			g.mode = Normal
+		}
+	case tb.KeySpace:
+		g.cmdline.AddChar(' ')
+	case tb.KeyEsc:
+		// Escape from a filter, restoring the whole list
+		g.cmdline.Clear()
+		g.mode = Normal
+	default:
+		if ev.Ch == 0 {
+			return
+		}
+		g.cmdline.AddChar(ev.Ch)
+	}
+	g.updatefilter()
+}
+
+// handleNormalKey runs the global binding for a key, or passes it to the
+// screen if there isn't one. While help is shown it gets every key but those
+// for commands and filtering.
+func (g *Spot) handleNormalKey(ev tb.Event) {
+	if g.help != nil && ev.Ch != ':' && ev.Ch != '/' {
+		g.help.HandleTBEvent(ev)
+		return
+	}
+	if !g.runKey(globalKeys, ev) {
+		g.currentscreen.HandleTBEvent(ev)
+	}
+}
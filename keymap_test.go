@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// keyNames returns a name for each key b binds
+func keyNames(b keybinding) (names []string) {
+	if b.ch != 0 {
+		names = append(names, string(b.ch))
+	}
+	for _, key := range b.keys {
+		names = append(names, fmt.Sprintf("key %d", key))
+	}
+	return
+}
+
+// TestKeybindingsReachable checks no binding is hidden by an earlier one in
+// its table, or by a global key, which would leave it in help but never run
+func TestKeybindingsReachable(t *testing.T) {
+	global := map[string]string{}
+	for _, b := range globalKeys {
+		for _, name := range keyNames(b) {
+			if _, ok := global[name]; ok {
+				t.Errorf("Global key %s is bound twice", b.name)
+			}
+			global[name] = b.help
+		}
+	}
+	tables := map[string][]keybinding{
+		"track list":      (&TrackList{}).keys(),
+		"browse":          (&SpotScreenBrowse{}).keys(),
+		"lyrics":          (&SpotScreenLyrics{}).keys(),
+		"EQ":              (&SpotScreenEQ{}).keys(),
+		"playlists":       (&SpotScreenPlaylists{}).keys(),
+		"playlist tracks": (&SpotScreenPlaylists{tracksfocussed: true}).keys(),
+	}
+	for table, bindings := range tables {
+		seen := map[string]bool{}
+		for _, b := range bindings {
+			if len(keyNames(b)) == 0 || b.help == "" || b.run == nil {
+				t.Errorf("%s binding %q is missing its key, help or action", table, b.name)
+			}
+			for _, name := range keyNames(b) {
+				if seen[name] {
+					t.Errorf("%s key %s is bound twice", table, b.name)
+				}
+				seen[name] = true
+				if help, ok := global[name]; ok {
+					t.Errorf("%s key %s is taken by the global key to %s", table, b.name, help)
+				}
+			}
+		}
+	}
+}
+
+func TestKeybindingMatches(t *testing.T) {
+	b := keybinding{keys: []tb.Key{tb.KeyArrowDown}, ch: 'j'}
+	tests := []struct {
+		ev   tb.Event
+		want bool
+	}{
+		{tb.Event{Ch: 'j'}, true},
+		{tb.Event{Key: tb.KeyArrowDown}, true},
+		{tb.Event{Ch: 'k'}, false},
+		{tb.Event{Key: tb.KeyArrowUp}, false},
+		{tb.Event{Key: tb.KeyEnter}, false},
+	}
+	for _, test := range tests {
+		if got := b.matches(test.ev); got != test.want {
+			t.Errorf("matches(%+v) = %t, want %t", test.ev, got, test.want)
+		}
+	}
+}
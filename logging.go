@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	sp "github.com/op/go-libspotify/spotify"
+)
+
+// LogLevel is how much a log line matters. Lines below the Logger's level
+// aren't written.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+	LogOff // Log nothing
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDebug:   "debug",
+	LogInfo:    "info",
+	LogWarning: "warning",
+	LogError:   "error",
+	LogOff:     "off",
+}
+
+// ParseLogLevel returns the level called name
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, n := range logLevelNames {
+		if n == strings.ToLower(name) {
+			return level, nil
+		}
+	}
+	return LogOff, fmt.Errorf("No such log level %q, try debug, info, warning, error or off", name)
+}
+
+const (
+	maxLogSize     = 4 << 20 // Bytes a log file grows to before it's rotated
+	maxLogFiles    = 3       // The log file and its rotated copies, .1 being the newest
+	recentLogLines = 100     // Lines kept in memory, for crash reports
+)
+
+// Logger writes lines tagged with a level and the component that logged them
+// to a file, rotating it when it gets too big. It's safe to use from any
+// goroutine, and a nil Logger logs nothing.
+type Logger struct {
+	mu     sync.Mutex
+	level  LogLevel
+	path   string
+	file   *os.File
+	size   int64
+	recent []string // The last recentLogLines lines logged, oldest first
+}
+
+// OpenLogger opens the log file at p for appending, creating it and its
+// directory if need be, and logs lines at level and above to it
+func OpenLogger(p string, level LogLevel) (*Logger, error) {
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	l := &Logger{level: level, path: p}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	if l.size >= maxLogSize {
+		if err := l.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// rotate moves each log file along one, dropping the oldest, and starts a
+// new one
+func (l *Logger) rotate() error {
+	l.file.Close()
+	for n := maxLogFiles - 1; n > 0; n-- {
+		from := l.path
+		if n > 1 {
+			from = fmt.Sprintf("%s.%d", l.path, n-1)
+		}
+		os.Rename(from, fmt.Sprintf("%s.%d", l.path, n)) // Missing files are fine
+	}
+	return l.open()
+}
+
+// Log writes a line for component at level, if the logger's level lets it
+func (l *Logger) Log(level LogLevel, component, format string, args ...interface{}) {
+	if l == nil || level < l.level {
+		return
+	}
+	line := fmt.Sprintf("%s %-7s [%s] %s\n", time.Now().Format("2006-01-02 15:04:05.000"),
+		strings.ToUpper(logLevelNames[level]), component, fmt.Sprintf(format, args...))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recent = append(l.recent, line)
+	if len(l.recent) > recentLogLines {
+		l.recent = l.recent[len(l.recent)-recentLogLines:]
+	}
+	if l.file == nil { // A rotation failed, so there's nowhere to write
+		return
+	}
+	if l.size+int64(len(line)) > maxLogSize {
+		if err := l.rotate(); err != nil {
+			l.file = nil
+			return
+		}
+	}
+	n, _ := l.file.WriteString(line)
+	l.size += int64(n)
+}
+
+// Recent returns the last lines logged, oldest first
+func (l *Logger) Recent() []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.recent...)
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// logger is what everything logs to. It's nil, so nothing is logged, until
+// main opens the log file.
+var logger *Logger
+
+// A LogComponent tags log lines with the part of spot they came from
+type LogComponent string
+
+const (
+	uiLog      LogComponent = "ui"
+	playerLog  LogComponent = "player"
+	audioLog   LogComponent = "audio"
+	sessionLog LogComponent = "session"
+)
+
+func (c LogComponent) Debugf(format string, args ...interface{}) {
+	logger.Log(LogDebug, string(c), format, args...)
+}
+
+func (c LogComponent) Infof(format string, args ...interface{}) {
+	logger.Log(LogInfo, string(c), format, args...)
+}
+
+func (c LogComponent) Warnf(format string, args ...interface{}) {
+	logger.Log(LogWarning, string(c), format, args...)
+}
+
+func (c LogComponent) Errorf(format string, args ...interface{}) {
+	logger.Log(LogError, string(c), format, args...)
+}
+
+// spotifyLogLevels maps libspotify's log levels to ours
+var spotifyLogLevels = map[sp.LogLevel]LogLevel{
+	sp.LogFatal:   LogError,
+	sp.LogError:   LogError,
+	sp.LogWarning: LogWarning,
+	sp.LogInfo:    LogInfo,
+	sp.LogDebug:   LogDebug,
+}
+
+// logSpotify forwards one of libspotify's own log messages into the log,
+// tagged with the libspotify module it came from
+func logSpotify(m *sp.LogMessage) {
+	level, ok := spotifyLogLevels[m.Level]
+	if !ok {
+		level = LogInfo
+	}
+	logger.Log(level, string(sessionLog)+"/"+m.Module, "%s", m.Message)
+}
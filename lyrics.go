@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tb "github.com/nsf/termbox-go"
+	sp "github.com/op/go-libspotify/spotify"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// LyricLine is a line of lyrics, sung Time into the track
+type LyricLine struct {
+	Time time.Duration
+	Text string
+}
+
+// Lyrics are the words of a track. If they're Synced, each line has the time
+// it's sung and they're in order of it; otherwise every Time is zero.
+type Lyrics struct {
+	Lines  []LyricLine
+	Synced bool
+}
+
+// LineAt returns the index of the line being sung elapsed into the track, or
+// -1 if it's before the first line or the lyrics aren't synced
+func (l *Lyrics) LineAt(elapsed time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].Time > elapsed }) - 1
+}
+
+var (
+	lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d+(?:[.:]\d+)?)\]`)
+	lrcTag       = regexp.MustCompile(`^\[([a-z#]+):(.*)\]$`)
+)
+
+// ParseLRC reads lyrics in the LRC format, where each line starts with the
+// times it's sung, e.g. "[01:02.50]Words". Tags such as [ar:Artist] are
+// skipped, except [offset:ms], which moves every line earlier by that many
+// milliseconds. Without any times the lyrics are read as plain text.
+func ParseLRC(r io.Reader) (*Lyrics, error) {
+	var plain, timed []LyricLine
+	var offset time.Duration
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := lrcTag.FindStringSubmatch(line); m != nil {
+			if m[1] == "offset" {
+				ms, err := strconv.Atoi(strings.TrimSpace(m[2]))
+				if err != nil {
+					return nil, fmt.Errorf("Bad offset %q", m[2])
+				}
+				offset = time.Duration(ms) * time.Millisecond
+			}
+			continue
+		}
+		var times []time.Duration
+		for {
+			m := lrcTimestamp.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			mins, _ := strconv.Atoi(m[1])
+			sec, err := strconv.ParseFloat(strings.Replace(m[2], ":", ".", 1), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Bad time %q", m[0])
+			}
+			times = append(times, time.Duration(mins)*time.Minute+time.Duration(sec*float64(time.Second)))
+			line = line[len(m[0]):]
+		}
+		line = strings.TrimSpace(line)
+		if len(times) == 0 {
+			plain = append(plain, LyricLine{Text: line})
+			continue
+		}
+		// A line sung more than once, such as a chorus, has several times
+		for _, t := range times {
+			if t -= offset; t < 0 {
+				t = 0
+			}
+			timed = append(timed, LyricLine{Time: t, Text: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// Untimed lines in synced lyrics can't be placed, so they're dropped
+	if len(timed) == 0 {
+		return &Lyrics{Lines: trimBlankLines(plain)}, nil
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].Time < timed[j].Time })
+	return &Lyrics{Lines: timed, Synced: true}, nil
+}
+
+// trimBlankLines returns lines without any blank lines at either end
+func trimBlankLines(lines []LyricLine) []LyricLine {
+	for len(lines) > 0 && lines[0].Text == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1].Text == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// ErrNoLyrics is returned by a LyricsProvider which has no lyrics for a track
+var ErrNoLyrics = errors.New("No lyrics found")
+
+// LyricsQuery describes the track to find lyrics for
+type LyricsQuery struct {
+	Artist, Title, Album string
+	Duration             time.Duration
+}
+
+// A LyricsProvider looks up the lyrics of tracks. It's called off the main
+// goroutine, so it can take its time.
+type LyricsProvider interface {
+	Name() string
+	Lyrics(q LyricsQuery) (*Lyrics, error) // ErrNoLyrics if it has none
+}
+
+// LRCDirProvider finds lyrics in .lrc (or .txt) files in a directory, and
+// the directories within it, named "Artist - Title" or just "Title".
+// Case, punctuation and spacing don't matter.
+type LRCDirProvider struct {
+	Dir string
+}
+
+func (p LRCDirProvider) Name() string {
+	return "lrc files in " + p.Dir
+}
+
+func (p LRCDirProvider) Lyrics(q LyricsQuery) (*Lyrics, error) {
+	full, short := lyricsKey(q.Artist+" - "+q.Title), lyricsKey(q.Title)
+	var found, fallback string
+	err := filepath.Walk(p.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".lrc" && ext != ".txt" {
+			return nil
+		}
+		switch lyricsKey(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))) {
+		case full:
+			found = p
+			return io.EOF // Stop walking
+		case short:
+			if fallback == "" {
+				fallback = p
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, ErrNoLyrics
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if found == "" {
+		found = fallback
+	}
+	if found == "" {
+		return nil, ErrNoLyrics
+	}
+	f, err := os.Open(found)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseLRC(f)
+}
+
+// lyricsKey returns name in lower case with only its letters and digits, for
+// matching file names against tracks
+func lyricsKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// LyricsConfig holds the settings for finding lyrics
+type LyricsConfig struct {
+	Dir string `json:"dir"` // Where .lrc files are kept, lyrics in the config dir if empty
+}
+
+// lyricsProviders returns the providers to look for lyrics with, in the
+// order they're tried
+func lyricsProviders(config LyricsConfig) []LyricsProvider {
+	dir := config.Dir
+	if dir == "" {
+		configdir, err := ConfigDir()
+		if err != nil {
+			uiLog.Warnf("No lyrics dir: %s", err)
+			return nil
+		}
+		dir = path.Join(configdir, "lyrics")
+	}
+	return []LyricsProvider{LRCDirProvider{Dir: dir}}
+}
+
+// SpotScreenLyrics shows the lyrics of the playing track. Synced lyrics
+// follow the track, with the line being sung picked out in the middle.
+type SpotScreenLyrics struct {
+	player    *SpotPlayer
+	providers []LyricsProvider
+	loaded    chan struct{} // Sent on when lyrics have been looked up, to redraw
+
+	mu     sync.Mutex // Guards track, lyrics and err, which lookups fill in
+	track  *sp.Track  // The track whose lyrics are loaded, or loading
+	lyrics *Lyrics
+	err    error
+
+	scroll    int  // The first row shown, when not following
+	following bool // Whether to keep the line being sung in the middle
+	rows      int  // How many rows the lyrics took when last drawn
+}
+
+func NewSpotScreenLyrics(player *SpotPlayer, providers []LyricsProvider) *SpotScreenLyrics {
+	return &SpotScreenLyrics{player: player, providers: providers, loaded: make(chan struct{}, 1), following: true}
+}
+
+// Loaded is sent on when lyrics have been looked up and the screen needs
+// redrawing
+func (s *SpotScreenLyrics) Loaded() <-chan struct{} {
+	return s.loaded
+}
+
+// current returns the playing track's lyrics, starting to look them up if
+// it's a different track from last time. Both are nil while they load.
+func (s *SpotScreenLyrics) current() (*Lyrics, error) {
+	track := s.player.track
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sameTrack(track, s.track) {
+		return s.lyrics, s.err
+	}
+	s.track, s.lyrics, s.err = track, nil, nil
+	s.scroll, s.following = 0, true
+	q := LyricsQuery{
+		Artist:   ArtistNames(track),
+		Title:    track.Name(),
+		Album:    AlbumName(track),
+		Duration: track.Duration(),
+	}
+	go func() {
+		defer recoverCrash("lyrics")
+		lyrics, err := s.lookup(q)
+		s.mu.Lock()
+		if sameTrack(s.track, track) {
+			s.lyrics, s.err = lyrics, err
+		}
+		s.mu.Unlock()
+		select {
+		case s.loaded <- struct{}{}:
+		default: // A redraw is already due
+		}
+	}()
+	return nil, nil
+}
+
+// lookup asks each provider in turn for q's lyrics
+func (s *SpotScreenLyrics) lookup(q LyricsQuery) (*Lyrics, error) {
+	for _, p := range s.providers {
+		lyrics, err := p.Lyrics(q)
+		if err == nil {
+			uiLog.Debugf("Lyrics for %q from %s", q.Title, p.Name())
+			return lyrics, nil
+		}
+		if err != ErrNoLyrics {
+			uiLog.Warnf("Looking up lyrics in %s failed: %s", p.Name(), err)
+		}
+	}
+	return nil, ErrNoLyrics
+}
+
+func (s *SpotScreenLyrics) Draw(x, y, w, h int) {
+	normal, dim := ui.StyleOf(ui.RoleNormal), ui.StyleOf(ui.RoleDim)
+	p := s.player
+	if p.playstate == Ejected || p.track == nil {
+		ui.Printc(x+w/2, y+h/2, dim.Fg, dim.Bg, "Nothing playing")
+		return
+	}
+	lyrics, err := s.current()
+	switch {
+	case err != nil:
+		ui.Printc(x+w/2, y+h/2, dim.Fg, dim.Bg, err.Error())
+		return
+	case lyrics == nil:
+		ui.Printc(x+w/2, y+h/2, dim.Fg, dim.Bg, "Looking for lyrics...")
+		return
+	}
+
+	// Long lines are wrapped, so each line takes one or more rows
+	type row struct {
+		text string
+		line int
+	}
+	var rows []row
+	first := make([]int, len(lyrics.Lines)) // The first row of each line
+	for i, l := range lyrics.Lines {
+		first[i] = len(rows)
+		wrapped := ui.Wrap(l.Text, w-2)
+		if len(wrapped) == 0 {
+			wrapped = []string{""}
+		}
+		for _, text := range wrapped {
+			rows = append(rows, row{text, i})
+		}
+	}
+	s.rows = len(rows)
+
+	current := lyrics.LineAt(p.elapsed)
+	if s.following && lyrics.Synced {
+		s.scroll = -h / 2
+		if current >= 0 {
+			s.scroll += first[current]
+		}
+	}
+	s.clampScroll()
+	highlit := ui.StyleOf(ui.RoleHighlit)
+	for i := 0; i < h; i++ {
+		n := s.scroll + i
+		if n < 0 || n >= len(rows) {
+			continue
+		}
+		style := normal
+		if lyrics.Synced {
+			switch {
+			case rows[n].line == current:
+				style = ui.Style{Fg: highlit.Fg | tb.AttrBold, Bg: normal.Bg}
+			case rows[n].line > current:
+				style = dim
+			}
+		}
+		ui.Printc(x+w/2, y+i, style.Fg, style.Bg, rows[n].text)
+	}
+}
+
+// clampScroll keeps the scroll within the lyrics when not following. Synced
+// lyrics may start above the top, so the first line can be in the middle.
+func (s *SpotScreenLyrics) clampScroll() {
+	if s.following {
+		return
+	}
+	if s.scroll > s.rows-1 {
+		s.scroll = s.rows - 1
+	}
+	if s.scroll < 0 {
+		s.scroll = 0
+	}
+}
+
+// scrollBy stops following the line being sung, and scrolls n rows down, or
+// up if n is negative. Following can scroll above the top, to keep the first
+// lines in the middle, so that's clamped first, or the move would be lost.
+func (s *SpotScreenLyrics) scrollBy(n int) {
+	s.following = false
+	s.clampScroll()
+	s.scroll += n
+}
+
+func (s *SpotScreenLyrics) keys() []keybinding {
+	return []keybinding{
+		{keys: []tb.Key{tb.KeyArrowDown}, ch: 'j', name: "j ↓", help: "Scroll down, up, leaving the line being sung", run: func(*Spot) {
+			s.scrollBy(1)
+		}},
+		{keys: []tb.Key{tb.KeyArrowUp}, ch: 'k', name: "k ↑", help: "Scroll down, up, leaving the line being sung", run: func(*Spot) {
+			s.scrollBy(-1)
+		}},
+		{ch: 'f', name: "f", help: "Follow the line being sung again", run: func(*Spot) {
+			s.following = true
+		}},
+	}
+}
+
+func (s *SpotScreenLyrics) Keys() []ui.KeyHelp {
+	return keyHelp(s.keys())
+}
+
+func (s *SpotScreenLyrics) HandleTBEvent(ev tb.Event) {
+	spot.runKey(s.keys(), ev)
+}
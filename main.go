@@ -1,9 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"os/user"
+	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -22,19 +23,18 @@ var version string
 type CmdLine struct {
 	Text    []rune
 	history [][]rune
-	status  string
 }
 
-func (c *CmdLine) Draw() {
-	_, y := tb.Size()
-	// If there is a status message, draw it, otherwise draw the current
-	// command in c.Text
-	if c.status != "" {
-		ui.Print(0, y-1, tb.ColorRed, tb.ColorDefault, c.status)
+// Draw draws the cmdline in r, which is a single row
+func (c *CmdLine) Draw(r ui.Rect, msg *LogMessage) {
+	// If there is a message, draw it, otherwise draw the current command in
+	// c.Text
+	if msg != nil {
+		style := ui.StyleOf(severityRoles[msg.Severity])
+		ui.Printlim(r.X, r.Y, style.Fg, style.Bg, msg.Text, r.W)
 	} else {
-		for i, r := range c.Text {
-			tb.SetCell(i, y-1, r, tb.ColorWhite, tb.ColorDefault)
-		}
+		style := ui.StyleOf(ui.RoleNormal)
+		ui.Print(r.X, r.Y, style.Fg, style.Bg, string(c.Text))
 	}
 }
 
@@ -61,19 +61,19 @@ func (c *CmdLine) Clear() {
 	c.Text = nil
 }
 
-// A status message and Termbox color attribute. For display in the top right
+// A status message and the theme role to draw it in. For display in the top right
 type StatusMsg struct {
-	Msg    string
-	Colour tb.Attribute
+	Msg  string
+	Role ui.Role
 }
 
 // Maps between spotify connectionstates to Statusmsg structs
 var ConnstateMsg = map[sp.ConnectionState]StatusMsg{
-	sp.ConnectionStateLoggedOut:    StatusMsg{"Logged Out", tb.ColorRed},
-	sp.ConnectionStateLoggedIn:     StatusMsg{"Logged In", tb.ColorGreen},
-	sp.ConnectionStateDisconnected: StatusMsg{"Disconnected", tb.ColorRed},
-	sp.ConnectionStateUndefined:    StatusMsg{"???", tb.ColorWhite},
-	sp.ConnectionStateOffline:      StatusMsg{"Offline", tb.ColorRed},
+	sp.ConnectionStateLoggedOut:    StatusMsg{"Logged Out", ui.RoleError},
+	sp.ConnectionStateLoggedIn:     StatusMsg{"Logged In", ui.RoleGood},
+	sp.ConnectionStateDisconnected: StatusMsg{"Disconnected", ui.RoleError},
+	sp.ConnectionStateUndefined:    StatusMsg{"???", ui.RoleNormal},
+	sp.ConnectionStateOffline:      StatusMsg{"Offline", ui.RoleError},
 }
 
 type Mode int
@@ -94,11 +94,13 @@ const (
 )
 
 type SpotPlayer struct {
-	spplayer  *sp.Player
-	track     *sp.Track
-	playstate PlayerState
-	elapsed   time.Duration
-	aw        *AudioWriter
+	spplayer     *sp.Player
+	track        *sp.Track
+	playstate    PlayerState
+	elapsed      time.Duration
+	aw           *AudioWriter
+	devicepaused bool        // Paused because the audio device failed, not by the user
+	queue        []*sp.Track // Tracks to play after this one, in order
 }
 
 func NewSpotPlayer(p *sp.Player, aw *AudioWriter) *SpotPlayer {
@@ -124,6 +126,22 @@ func (p *SpotPlayer) PlayPause() {
 	}
 }
 
+// DeviceLost pauses playback while the audio device is unavailable
+func (p *SpotPlayer) DeviceLost() {
+	if p.playstate == Playing {
+		p.PlayPause()
+		p.devicepaused = true
+	}
+}
+
+// DeviceRestored resumes playback if it was paused by DeviceLost
+func (p *SpotPlayer) DeviceRestored() {
+	if p.devicepaused && p.playstate == Paused {
+		p.PlayPause()
+	}
+	p.devicepaused = false
+}
+
 func (p *SpotPlayer) Stop() {
 	// Pause and seek 0
 	switch p.playstate {
@@ -143,13 +161,55 @@ func (p *SpotPlayer) Load(tr *sp.Track) (err error) {
 		p.Eject()
 	}
 	err = p.spplayer.Load(tr)
-	if err == nil {
-		p.playstate = Stopped
-		p.track = tr
+	if err != nil {
+		playerLog.Errorf("Couldn't load %s: %s", tr.Name(), err)
+		return
 	}
+	playerLog.Infof("Loaded %s - %s", tr.Name(), ArtistNames(tr))
+	p.playstate = Stopped
+	p.track = tr
 	return
 }
 
+// PlayFrom plays the first of tracks, and queues up the rest to play after it
+func (p *SpotPlayer) PlayFrom(tracks []*sp.Track) error {
+	if len(tracks) == 0 {
+		return errors.New("Nothing to play")
+	}
+	tracks[0].Wait()
+	if err := p.Load(tracks[0]); err != nil {
+		return err
+	}
+	p.PlayPause()
+	p.queue = tracks[1:]
+	return nil
+}
+
+// Enqueue adds tracks to the end of the queue, or plays them if nothing is
+// loaded
+func (p *SpotPlayer) Enqueue(tracks []*sp.Track) error {
+	if p.playstate == Ejected {
+		return p.PlayFrom(tracks)
+	}
+	p.queue = append(p.queue, tracks...)
+	return nil
+}
+
+// Next plays the next track in the queue that will load, returning false if
+// there isn't one
+func (p *SpotPlayer) Next() bool {
+	for len(p.queue) > 0 {
+		track := p.queue[0]
+		p.queue = p.queue[1:]
+		track.Wait()
+		if p.Load(track) == nil {
+			p.PlayPause()
+			return true
+		}
+	}
+	return false
+}
+
 func (p *SpotPlayer) Eject() {
 	p.aw.Flush()
 	p.spplayer.Unload()
@@ -159,13 +219,9 @@ func (p *SpotPlayer) Eject() {
 }
 
 func (p *SpotPlayer) NowPlaying() map[string]string {
-	artists := p.track.Artist(0).Name()
-	for i := 1; i < p.track.Artists(); i++ {
-		artists += ", " + p.track.Artist(i).Name()
-	}
 	return map[string]string{
-		"artist":   artists,
-		"album":    p.track.Album().Name(),
+		"artist":   ArtistNames(p.track),
+		"album":    AlbumName(p.track),
 		"track":    p.track.Name(),
 		"duration": PrettyDuration(p.track.Duration()),
 		"elapsed":  PrettyDuration(p.elapsed),
@@ -183,6 +239,7 @@ func (p *SpotPlayer) Seek(pos time.Duration) {
 	if pos > p.track.Duration() {
 		pos = p.track.Duration()
 	}
+	playerLog.Debugf("Seek to %s", pos)
 	p.spplayer.Seek(pos)
 	p.aw.Flush()
 	p.elapsed = pos
@@ -193,35 +250,73 @@ func (p *SpotPlayer) Scrub(offset time.Duration) {
 }
 
 type Spot struct {
-	session         *sp.Session
-	logger          *log.Logger
-	cmdline         CmdLine
-	quit            bool
-	mode            Mode
-	loggedin        bool
-	Player          *SpotPlayer
-	audiowriter     *AudioWriter
-	currentscreen   SpotScreen
-	screenabout     *SpotScreenAbout
-	screenplaylists *SpotScreenPlaylists
-}
-
-func SpotInit(logger *log.Logger, session *sp.Session, aw *AudioWriter) (spot Spot) {
+	session       *sp.Session
+	cmdline       CmdLine
+	quit          bool
+	mode          Mode
+	loggedin      bool
+	Player        *SpotPlayer
+	audiowriter   *AudioWriter
+	config        Config
+	currentscreen SpotScreen
+	screens       map[string]registeredScreen // Screens which can be shown by name
+	history       []SpotScreen                // Screens to go back to, the last most recent
+	forward       []SpotScreen                // Screens gone back from, the last most recent
+	tabrects      []ui.Rect                   // Where each tab was last drawn
+	dialog        ui.Dialog                   // Takes every key while open, and is drawn over everything
+	messages      *MessageLog                 // Shown in the cmdline, and on the messages screen
+	notifier      *Notifier                   // Connected when the first notification is due
+	nonotify      bool                        // Set when notifying failed, to stop trying
+	notified      *sp.Track                   // The track last notified of
+	layout        *ui.Split                   // The rows of the screen, from the top bar down to the cmdline
+	rows          []ui.Rect                   // Where the rows were last drawn
+	help          *SpotHelp                   // Shown over the current screen, if open
+	graphics      GraphicScreen               // The screen whose images are on the terminal
+}
+
+// The rows of Spot's layout
+const (
+	topBarRow = iota
+	screenRow
+	nowPlayingRow
+	cmdLineRow
+)
+
+// Below this width there's no room to show anything useful
+const minTermWidth = 20
+
+func SpotInit(session *sp.Session, aw *AudioWriter, config Config) (spot Spot) {
 	a := SpotScreenAbout{}
-	p := NewSpotScreenPlaylists()
+	p := NewSpotScreenPlaylists(config.TrackColumns)
+	e := NewSpotScreenEQ(aw.DSP)
+	v := NewSpotScreenVisualiser(aw.Tap)
+	d := SpotScreenDiagnostics{aw: aw}
+	messages := &MessageLog{}
+	m := NewSpotScreenMessages(messages)
+	player := NewSpotPlayer(session.Player(), aw)
+	n := NewSpotScreenNowPlaying(player, config.AlbumArt)
+	l := NewSpotScreenLyrics(player, lyricsProviders(config.Lyrics))
 	spot = Spot{
-		session:         session,
-		logger:          logger,
-		cmdline:         CmdLine{},
-		quit:            false,
-		mode:            Normal,
-		Player:          NewSpotPlayer(session.Player(), aw),
-		audiowriter:     aw,
-		currentscreen:   &a,
-		screenabout:     &a,
-		screenplaylists: &p,
-		loggedin:        false,
+		session:       session,
+		cmdline:       CmdLine{},
+		quit:          false,
+		mode:          Normal,
+		Player:        player,
+		audiowriter:   aw,
+		config:        config,
+		currentscreen: &a,
+		loggedin:      false,
+		messages:      messages,
+		layout:        ui.NewSplit(ui.Vertical, false, ui.Fixed(1), ui.Flex().AtLeast(3), ui.Fixed(1), ui.Fixed(1)),
 	}
+	spot.RegisterScreen(screenAbout, "About", &a)
+	spot.RegisterScreen(screenPlaylists, "Playlists", &p)
+	spot.RegisterScreen(screenEQ, "EQ", &e)
+	spot.RegisterScreen(screenVis, "Visualiser", &v)
+	spot.RegisterScreen(screenDiag, "Diagnostics", &d)
+	spot.RegisterScreen(screenMessages, "Messages", &m)
+	spot.RegisterScreen(screenNowPlaying, "Now Playing", n)
+	spot.RegisterScreen(screenLyrics, "Lyrics", l)
 	return
 
 }
@@ -232,24 +327,126 @@ var PlayerstateSymbols = map[PlayerState]string{
 	Paused:  "|",
 }
 
+// showplaylists switches to the playlists screen, returning a status
+// message if it can't
+func (g *Spot) showplaylists() string {
+	if !g.loggedin {
+		return "Not logged in"
+	}
+	playlists, err := g.session.Playlists()
+	if err != nil {
+		return g.fail(err.Error())
+	}
+	playlists.Wait()
+	g.playlistsScreen().SetPlaylists(playlists)
+	g.Push(g.playlistsScreen())
+	return ""
+}
+
+// playlistsScreen returns the registered playlists screen
+func (g *Spot) playlistsScreen() *SpotScreenPlaylists {
+	return g.Screen(screenPlaylists).(*SpotScreenPlaylists)
+}
+
+// nowPlayingScreen returns the registered now playing screen
+func (g *Spot) nowPlayingScreen() *SpotScreenNowPlaying {
+	return g.Screen(screenNowPlaying).(*SpotScreenNowPlaying)
+}
+
+// lyricsScreen returns the registered lyrics screen
+func (g *Spot) lyricsScreen() *SpotScreenLyrics {
+	return g.Screen(screenLyrics).(*SpotScreenLyrics)
+}
+
+// updatefilter filters the active screen's list by what has been typed
+// after the / in the cmdline
+func (g *Spot) updatefilter() {
+	screen, ok := g.activescreen().(FilterableScreen)
+	if !ok {
+		return
+	}
+	if len(g.cmdline.Text) > 0 {
+		screen.SetFilter(string(g.cmdline.Text[1:]))
+	} else {
+		screen.SetFilter("")
+	}
+}
+
+// StartCommand switches to command mode with text already typed after the
+// colon, for the user to finish off
+func (g *Spot) StartCommand(text string) {
+	g.mode = Command
+	g.messages.Dismiss()
+	g.cmdline.Text = []rune(":" + text)
+}
+
+// OpenDialog shows d over everything else until it closes
+func (g *Spot) OpenDialog(d ui.Dialog) {
+	g.dialog = d
+}
+
+// Confirm asks the user a yes/no question. If they answer yes, action is run
+// and its result shown as the status.
+func (g *Spot) Confirm(question string, action func() string) {
+	g.OpenDialog(ui.NewConfirm("Confirm", question, func(yes bool) {
+		if yes {
+			g.status(action())
+		} else {
+			g.status("Cancelled")
+		}
+	}))
+}
+
+// handleDialogKey passes a keypress to the open dialog, and closes it once
+// it's done, unless it opened another dialog as it closed
+func (g *Spot) handleDialogKey(ev tb.Event) {
+	d := g.dialog
+	if d.HandleKey(ev) && g.dialog == d {
+		g.dialog = nil
+	}
+}
+
 // (re)Draws the spot UI
 func (g *Spot) redraw() {
-	tb.Clear(tb.ColorWhite, tb.ColorDefault)
+	normal := ui.StyleOf(ui.RoleNormal)
+	tb.Clear(normal.Fg, normal.Bg)
 	termw, termh := tb.Size()
-	// Draw top bar
-	ui.Drawbar(0, 0, termw, tb.ColorBlack)
-	ui.Print(0, 0, tb.AttrBold, tb.ColorBlack, "Spot "+version)
+	screen := ui.Rect{W: termw, H: termh}
+	rows, ok := g.layout.Layout(screen)
+	if !ok || termw < minTermWidth {
+		ui.DrawTooSmall(screen)
+		tb.Flush()
+		return
+	}
+	g.rows = rows
 
-	// Get the StatusMsg (message and color) for current spotify session state
-	// and print it at the top right
+	// Draw top bar
+	top := rows[topBarRow]
+	bar, title := ui.StyleOf(ui.RoleBar), ui.StyleOf(ui.RoleTitle)
+	ui.Fill(top.X, top.Y, top.W, bar)
+	name := "Spot " + version + " "
+	ui.Print(top.X, top.Y, title.Fg, title.Bg, name)
+
+	// Get the StatusMsg (message and role) for current spotify session state
+	// and print it at the top right, on the bar
 	statusmsg := ConnstateMsg[g.session.ConnectionState()]
-	ui.Printr(termw, 0, statusmsg.Colour, tb.ColorBlack, statusmsg.Msg)
+	ui.Printr(top.X+top.W, top.Y, ui.StyleOf(statusmsg.Role).Fg|bar.Attrs(), bar.Bg, statusmsg.Msg)
+
+	// Tabs for the open screens go in between
+	tabsx := top.X + ui.StringWidth(name)
+	g.drawTabs(ui.Rect{X: tabsx, Y: top.Y, W: top.W - ui.StringWidth(name) - ui.StringWidth(statusmsg.Msg) - 1, H: 1})
 
 	// Draw active screen
-	g.currentscreen.Draw(0, 1, termw, termh-3)
+	r := rows[screenRow]
+	g.currentscreen.Draw(r.X, r.Y, r.W, r.H)
+	if g.help != nil {
+		g.help.Draw(r.X, r.Y, r.W, r.H)
+	}
 
 	// Draw nowplaying
-	ui.Drawbar(0, termh-2, termw, tb.ColorBlack)
+	np := rows[nowPlayingRow]
+	nowplaying := ui.StyleOf(ui.RoleNowPlaying)
+	ui.Fill(np.X, np.Y, np.W, nowplaying)
 	var nowplayingstr string
 	switch g.Player.playstate {
 	case Ejected:
@@ -258,77 +455,318 @@ func (g *Spot) redraw() {
 		np := g.Player.NowPlaying()
 		nowplayingstr = fmt.Sprintf("%s %s/%s %s - %s", PlayerstateSymbols[g.Player.playstate], np["elapsed"], np["duration"], np["track"], np["artist"])
 	}
-	ui.Print(0, termh-2, tb.ColorBlue, tb.ColorBlack, nowplayingstr)
+	ui.Printlim(np.X, np.Y, nowplaying.Fg, nowplaying.Bg, nowplayingstr, np.W)
 
 	// Draw Cmdline
-	g.cmdline.Draw()
+	g.cmdline.Draw(rows[cmdLineRow], g.messages.Current(time.Now()))
+
+	if g.dialog != nil {
+		g.dialog.Draw(screen)
+	}
 	tb.Flush()
+	g.drawgraphics()
 }
 
-func (g *Spot) docommand(cmd string, args []string) string {
-	switch cmd {
-	case "q", "quit":
-		g.quit = true
-	case "login":
-		if len(args) != 2 {
-			return "Usage: :login [username] [password]"
+// handleMouse switches screens when a tab is clicked, seeks when the now
+// playing bar is clicked, at the point across the bar that was clicked, and
+// passes any other mouse events to the screen
+func (g *Spot) handleMouse(ev tb.Event) {
+	if g.rows == nil {
+		return
+	}
+	if ev.Key == tb.MouseLeft && g.rows[topBarRow].Contains(ev.MouseX, ev.MouseY) {
+		for i, r := range g.tabrects {
+			if r.Contains(ev.MouseX, ev.MouseY) {
+				g.help = nil
+				g.GotoTab(i)
+			}
 		}
-		err := g.session.Login(sp.Credentials{
-			Username: args[0],
-			Password: args[1],
-		}, true)
-		if err != nil {
-			return "Login Error!"
+		return
+	}
+	np := g.rows[nowPlayingRow]
+	if !np.Contains(ev.MouseX, ev.MouseY) {
+		g.activescreen().HandleTBEvent(ev)
+		return
+	}
+	if ev.Key != tb.MouseLeft || ev.Mod&tb.ModMotion != 0 || g.Player.track == nil || np.W < 2 {
+		return
+	}
+	frac := float64(ev.MouseX-np.X) / float64(np.W-1)
+	g.Player.Seek(time.Duration(frac * float64(g.Player.track.Duration())))
+}
+
+// focussedtracks returns the current screen's track list, if it has one
+// with focus
+func (g *Spot) focussedtracks() *TrackList {
+	if screen, ok := g.currentscreen.(TrackScreen); ok {
+		return screen.FocussedTracks()
+	}
+	return nil
+}
+
+// targettracks returns the tracks that track commands act on: the marked
+// ones, or the selected one, if a track list has focus, otherwise whatever
+// is playing
+func (g *Spot) targettracks() []*sp.Track {
+	if tracks := g.focussedtracks(); tracks != nil && tracks.Len() > 0 {
+		return tracks.TargetTracks()
+	}
+	if g.Player.track == nil {
+		return nil
+	}
+	return []*sp.Track{g.Player.track}
+}
+
+// clearmarks unmarks the tracks once a command has acted on them
+func (g *Spot) clearmarks() {
+	if tracks := g.focussedtracks(); tracks != nil {
+		tracks.ClearMarks()
+	}
+}
+
+// addtoplaylist adds the target tracks to the named playlist
+func (g *Spot) addtoplaylist(name string) string {
+	if !g.loggedin {
+		return "Login first!"
+	}
+	screen := g.playlistsScreen()
+	tracks := g.targettracks()
+	if len(tracks) == 0 {
+		return "No track to add"
+	}
+	playlist, err := screen.FindPlaylist(name)
+	if err != nil {
+		return g.fail(err.Error())
+	}
+	if err := screen.AddTracks(playlist, tracks); err != nil {
+		return g.fail(err.Error())
+	}
+	g.clearmarks()
+	return fmt.Sprintf("Added %s to %s", describeTracks(tracks), playlist.Name())
+}
+
+// logout logs out, and if forget is set forgets the saved login so Spot
+// doesn't log back in next time
+func (g *Spot) logout(forget bool) string {
+	g.ShowScreen(screenAbout)
+	if err := g.session.Logout(); err != nil {
+		return g.fail(err.Error())
+	}
+	g.loggedin = false
+	if forget {
+		if err := g.session.ForgetMe(); err != nil {
+			return g.fail(err.Error())
 		}
-	case "logout":
-		g.currentscreen = g.screenabout // Swap to about screen TODO:less magic numbery
-		err := g.session.Logout()
-		if err != nil {
-			return err.Error()
+	}
+	return ""
+}
+
+// enqueue queues up the target tracks to play after everything already queued
+func (g *Spot) enqueue() string {
+	tracks := g.targettracks()
+	if len(tracks) == 0 {
+		return "No track to queue"
+	}
+	if err := g.Player.Enqueue(tracks); err != nil {
+		return g.fail(err.Error())
+	}
+	g.clearmarks()
+	return "Queued " + describeTracks(tracks)
+}
+
+// copylinks copies the links of the target tracks to the clipboard, one per line
+func (g *Spot) copylinks() string {
+	tracks := g.targettracks()
+	if len(tracks) == 0 {
+		return "No track to copy"
+	}
+	links := make([]string, len(tracks))
+	for i, track := range tracks {
+		links[i] = track.Link().String()
+	}
+	if err := CopyToClipboard(strings.Join(links, "\n")); err != nil {
+		return g.fail(err.Error())
+	}
+	g.clearmarks()
+	return "Copied links to " + describeTracks(tracks)
+}
+
+// playlistcommand handles the :playlist command, which organises the
+// playlists and folders in the playlist pane
+func (g *Spot) playlistcommand(args []string) string {
+	usage := "Usage: :playlist new|folder|rename <name>, or :playlist delete|up|down"
+	screen := g.playlistsScreen()
+	if !g.loggedin || screen.playlists == nil {
+		return "Open the playlists screen first"
+	}
+	if len(args) == 0 {
+		return usage
+	}
+	name := strings.Join(args[1:], " ")
+	switch args[0] {
+	case "new", "folder", "rename":
+		if name == "" {
+			return usage
 		}
-		g.loggedin = false
-		// If the user issues a logout command, we assume they want to stay
-		// logged out
-		err = g.session.ForgetMe()
-		if err != nil {
-			return err.Error()
+	}
+	switch args[0] {
+	case "new":
+		return screen.NewPlaylist(name)
+	case "folder":
+		return screen.NewFolder(name)
+	case "rename":
+		return screen.RenameSelected(name)
+	case "delete":
+		if len(screen.playlistsSL.Items) == 0 {
+			return "Nothing to delete"
 		}
-	case "r", "relogin":
-		// TODO: Make this default somehow?
-		err := g.session.Relogin()
-		if err != nil {
-			return err.Error()
+		if !screen.selectedInContainer() {
+			// Starred and Inbox can't be deleted, so don't ask
+			return "Select one of your playlists to delete"
 		}
-	case "load", "l":
-		if !g.loggedin {
-			return "Login first!"
+		g.Confirm("Delete "+screen.SelectedName()+"?", screen.DeleteSelected)
+		return ""
+	case "up":
+		return screen.MoveSelected(-1)
+	case "down":
+		return screen.MoveSelected(1)
+	}
+	return usage
+}
+
+// togglestar stars the target tracks, or unstars them if they are all
+// already starred
+func (g *Spot) togglestar() string {
+	if !g.loggedin {
+		return "Login first!"
+	}
+	screen := g.playlistsScreen()
+	tracks := g.targettracks()
+	if len(tracks) == 0 {
+		return "No track to star"
+	}
+	starred := false
+	for _, track := range tracks {
+		starred = starred || !track.IsStarred()
+	}
+	for _, track := range tracks {
+		track.SetStarred(starred)
+	}
+	g.clearmarks()
+	screen.tracksSL.Refresh()
+	if tracks := g.focussedtracks(); tracks != nil {
+		tracks.Refresh()
+	}
+	if len(screen.playlistsSL.Items) > 0 && screen.selectedIndex() == starredItem {
+		// Show the change in the Starred list
+		screen.playlistchanged = true
+	}
+	if starred {
+		return "Starred " + describeTracks(tracks)
+	}
+	return "Unstarred " + describeTracks(tracks)
+}
+
+// tracklists returns every open track list: the playlists screen's, and
+// those of the browse screens which can be gone back or forward to
+func (g *Spot) tracklists() []*TrackList {
+	lists := []*TrackList{g.playlistsScreen().tracksSL}
+	screens := append([]SpotScreen{g.currentscreen}, g.history...)
+	for _, screen := range append(screens, g.forward...) {
+		if browse, ok := screen.(*SpotScreenBrowse); ok {
+			lists = append(lists, browse.tracks)
 		}
-		link, err := g.session.ParseLink(args[0])
-		if err != nil {
-			return err.Error()
+	}
+	return lists
+}
+
+// columnscommand handles the :columns command, which sets and saves the
+// columns shown in track lists
+func (g *Spot) columnscommand(args []string) string {
+	if len(args) == 0 {
+		return "Columns: " + strings.Join(TrackColumnNames(), ", ")
+	}
+	for _, tracks := range g.tracklists() {
+		if err := tracks.SetColumns(args); err != nil {
+			return g.fail(err.Error())
 		}
-		track, err := link.Track()
-		if err != nil {
-			return err.Error()
+	}
+	g.config.TrackColumns = args
+	if err := g.config.Save(); err != nil {
+		return g.fail("Couldn't save columns: " + err.Error())
+	}
+	return ""
+}
+
+// eqcommand handles the :eq command. With no arguments it shows the
+// equaliser screen, otherwise it changes the DSP settings and saves them.
+func (g *Spot) eqcommand(args []string) string {
+	usage := "Usage: :eq [on|off|preset <name>|mono <on|off>|balance <-1..1>]"
+	dsp := g.audiowriter.DSP
+	if len(args) == 0 {
+		g.ShowScreen(screenEQ)
+		return ""
+	}
+	switch args[0] {
+	case "on", "off":
+		dsp.SetEnabled(args[0] == "on")
+	case "preset":
+		if len(args) != 2 {
+			return "Presets: " + strings.Join(EQPresetNames(), ", ")
 		}
-		track.Wait()
-		if err := g.Player.Load(track); err != nil {
-			return err.Error()
+		if err := dsp.SetPreset(args[1]); err != nil {
+			return g.fail(err.Error())
+		}
+	case "mono":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return usage
 		}
-		return "Loaded!"
-	case "eject", "e":
-		g.Player.Eject()
-	case "seek", "s":
-		if len(args) != 1 {
-			return "Usage: seek <seconds>"
+		dsp.SetMono(args[1] == "on")
+	case "balance":
+		if len(args) != 2 {
+			return usage
 		}
-		secs, err := strconv.Atoi(args[0])
+		balance, err := strconv.ParseFloat(args[1], 64)
 		if err != nil {
-			return "Enter a valid number of seconds"
+			return "Enter a balance between -1 (left) and 1 (right)"
 		}
-		g.Player.Seek(time.Duration(secs) * time.Second)
+		dsp.SetBalance(balance)
 	default:
-		return "No such command"
+		return usage
+	}
+	return g.saveEQ()
+}
+
+// themecommand handles the :theme command, which lists the themes or switches
+// to one and saves it
+func (g *Spot) themecommand(args []string) string {
+	if len(args) == 0 {
+		names := ui.ThemeNames()
+		for i, name := range names {
+			if name == ui.CurrentTheme().Name {
+				names[i] = name + "*"
+			}
+		}
+		return "Themes: " + strings.Join(names, ", ")
+	}
+	theme, ok := ui.Themes[args[0]]
+	if !ok {
+		return "No such theme " + args[0]
+	}
+	ui.SetTheme(theme)
+	g.config.Theme = theme.Name
+	if err := g.config.Save(); err != nil {
+		return g.fail("Couldn't save theme: " + err.Error())
+	}
+	return ""
+}
+
+// saveEQ stores the DSP chain's current settings in the config file,
+// returning a status message if that fails
+func (g *Spot) saveEQ() string {
+	g.config.EQ = g.audiowriter.DSP.Settings()
+	if err := g.config.Save(); err != nil {
+		return g.fail("Couldn't save EQ settings: " + err.Error())
 	}
 	return ""
 }
@@ -337,120 +775,115 @@ func (g *Spot) run() {
 	eventCh := make(chan tb.Event)
 	wg := new(sync.WaitGroup)
 	go func() {
+		defer recoverCrash("event")
 		wg.Add(1)
+		// Read raw, to pick out focus reports, which termbox doesn't know
+		data := make([]byte, 256)
 		for {
-			ev := tb.PollEvent()
-			if ev.Type == tb.EventInterrupt {
+			ev := tb.PollRawEvent(data)
+			switch ev.Type {
+			case tb.EventInterrupt:
 				// We use this as a signal to terminate
 				close(eventCh)
 				wg.Done()
-				break
+				return
+			case tb.EventRaw:
+				for _, ev := range parseInput(data[:ev.N], tb.ParseEvent) {
+					eventCh <- ev
+				}
+			default:
+				eventCh <- ev
 			}
-			eventCh <- ev
 		}
 	}()
 
+	// Animated screens are redrawn on every tick of frames, as well as when
+	// something happens
+	frames := time.NewTicker(time.Second / 30)
+	defer frames.Stop()
+	// Messages which time out are checked every second until they have
+	seconds := time.NewTicker(time.Second)
+	defer seconds.Stop()
+
 	for {
+		var frameticks <-chan time.Time
+		if g.currentscreen == g.Screen(screenVis) || g.currentscreen == g.Screen(screenDiag) {
+			frameticks = frames.C
+		}
+		var expiryticks <-chan time.Time
+		if g.messages.Expiring() {
+			expiryticks = seconds.C
+		}
 		// Main run loop. Switch on termbox events (and later stuff from
 		// audio?)
 		select {
 		case ev := <-eventCh:
 			switch ev.Type {
 			case tb.EventKey:
-				switch ev.Key {
-				case tb.KeyEnter:
-					if g.mode == Command { // Finish command
-						g.mode = Normal
-						if len(g.cmdline.Text) > 1 {
-							banana := strings.Split(string(g.cmdline.Text[1:]), " ")
-							if result := g.docommand(banana[0], banana[1:]); result != "" {
-								g.cmdline.status = result
-							}
-							g.cmdline.Push()
-						}
-					} else {
-						g.currentscreen.HandleTBEvent(ev)
-					}
-				case tb.KeyBackspace, tb.KeyBackspace2:
-					if g.mode == Command {
-						g.cmdline.DelChar()
-					}
-				case tb.KeyDelete:
-					if g.mode == Command {
-						// TODO: this, requires a cursor
-					}
-				case tb.KeySpace:
-					if g.mode == Command {
-						g.cmdline.AddChar(' ')
-					}
-				case tb.KeyEsc:
-					if g.mode == Command {
-						g.cmdline.Clear()
-						g.mode = Normal
-					}
-					g.mode = Normal
-				case tb.KeyTab, tb.KeyArrowUp, tb.KeyArrowDown:
-					g.currentscreen.HandleTBEvent(ev)
-				case tb.KeyArrowLeft:
-					g.Player.Scrub(time.Duration(-10) * time.Second)
-				case tb.KeyArrowRight:
-					g.Player.Scrub(time.Duration(10) * time.Second)
-				default:
-					if g.mode == Command && ev.Ch != 0 {
-						g.cmdline.AddChar(ev.Ch)
-					} else {
-						// run keybinding TODO: make more configurable
-						switch ev.Ch {
-						case ':':
-							g.mode = Command
-							g.cmdline.status = ""
-							g.cmdline.AddChar(':')
-						case 'q':
-							//Quit
-							g.quit = true
-						case 'c':
-							g.Player.PlayPause()
-						case 'v':
-							g.Player.Stop()
-						case '0':
-							g.currentscreen = g.screenabout
-						case '1':
-							if !g.loggedin {
-								g.cmdline.status = "Not logged in"
-								break
-							}
-							playlists, err := g.session.Playlists()
-							if err != nil {
-								g.cmdline.status = err.Error()
-								break
-							}
-							playlists.Wait()
-							g.screenplaylists.SetPlaylists(playlists)
-							g.currentscreen = g.screenplaylists
-						}
-					}
+				if g.dialog != nil {
+					g.handleDialogKey(ev)
+					break
+				}
+				g.handleKey(ev)
+			case tb.EventMouse:
+				if g.dialog == nil {
+					g.handleMouse(ev)
 				}
 			case tb.EventResize:
+				if g.graphics != nil {
+					g.graphics.ClearGraphics(os.Stdout) // To place them again at the new size
+				}
 				g.redraw()
 			}
 		case err := <-g.session.LoggedInUpdates():
 			if err != nil {
-				g.cmdline.status = err.Error()
+				sessionLog.Errorf("Login failed: %s", err)
+				g.fail("Login failed: " + err.Error())
 			} else {
+				sessionLog.Infof("Logged in")
 				g.loggedin = true
 			}
 		case <-g.session.LoggedOutUpdates():
-			g.cmdline.status = "Logged out"
+			sessionLog.Infof("Logged out")
+			g.status("Logged out")
 			g.loggedin = false
 		case <-g.session.ConnectionStateUpdates():
-			// Do nothing, we just want to trigger a redraw
+			sessionLog.Debugf("Connection state %d", g.session.ConnectionState())
+		case m := <-g.session.LogMessages():
+			logSpotify(m)
 		case <-g.session.EndOfTrackUpdates():
-			g.Player.Stop() // We use this to Synchronise Player's state
+			playerLog.Debugf("End of track, %d queued", len(g.Player.queue))
+			if !g.Player.Next() {
+				g.Player.Stop() // We use this to Synchronise Player's state
+			}
 		case time := <-g.audiowriter.Ticks:
 			g.Player.AddElapsed(time)
+		case ev := <-g.audiowriter.Events:
+			if ev.Err != nil {
+				audioLog.Warnf("Device error: %s, retrying in %s", ev.Err, ev.Retry)
+				g.Player.DeviceLost()
+				g.warn(fmt.Sprintf("Audio device error: %s (retrying in %s)", ev.Err, ev.Retry))
+			} else {
+				audioLog.Infof("Device recovered")
+				g.Player.DeviceRestored()
+				g.status("Audio device recovered")
+			}
+		case <-g.nowPlayingScreen().Loaded():
+			// Redraw with the cover
+		case <-g.lyricsScreen().Loaded():
+			// Redraw with the lyrics
+		case <-frameticks:
+			// Nothing to do but redraw
+		case <-expiryticks:
+			// Redraw, dropping the message if it has timed out
+		}
+		if g.Player.playstate == Playing && !sameTrack(g.Player.track, g.notified) {
+			g.notified = g.Player.track
+			g.notifytrack()
 		}
 		g.redraw()
 		if g.quit {
+			uiLog.Infof("Quitting")
 			// Clean up libspotify stuff before we terminate
 			g.session.Logout()
 			g.session.Close()
@@ -466,13 +899,16 @@ func parseArgs() (args map[string]interface{}, err error) {
 	usage := `spot
 
 Usage:
-	spot
+	spot [--device=<name>] [--log-level=<level>] [--log-file=<path>]
 	spot -h | --help
 	spot -v | --version
 
 Options:
-	-h, --help        Show this help text
-	-v, --version     Display spot's version
+	-h, --help           Show this help text
+	-v, --version        Display spot's version
+	--device=<name>      Play through the named libao driver, e.g. pulse or alsa
+	--log-level=<level>  Log debug, info, warning, error or off [default: info]
+	--log-file=<path>    Log to path instead of spot.log in the cache directory
 `
 	args, err = docopt.Parse(usage, nil, true, "Spot "+version, false)
 	return
@@ -481,33 +917,75 @@ Options:
 var spot Spot // Yes, global scope.
 
 func main() {
-	_, err := parseArgs()
+	defer recoverCrash("main")
+	args, err := parseArgs()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	device, _ := args["--device"].(string)
+	loglevel, err := ParseLogLevel(args["--log-level"].(string))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cachedir, err := CacheDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	settingsdir, err := SettingsDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logpath, _ := args["--log-file"].(string)
+	if logpath == "" {
+		logpath = path.Join(cachedir, "spot.log")
+	}
+	if loglevel != LogOff {
+		if logger, err = OpenLogger(logpath, loglevel); err != nil {
+			log.Fatalln("Couldn't open the log file:", err)
+		}
+	}
+	uiLog.Infof("Spot %s starting", version)
 	err = tb.Init()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer tb.Close()
+	defer closeTerminal()
+	tb.SetInputMode(tb.InputEsc | tb.InputMouse)
+	startFocusReporting()
 	AudioInit()
 	defer AudioDeinit()
-	aw, _ := NewAudioWriter()
-	usr, err := user.Current()
+	aw, err := NewAudioWriter(device)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	aw.DSP.SetSettings(config.EQ)
+	ui.InitColours()
+	themeerr := LoadThemes()
+	if theme, ok := ui.Themes[config.Theme]; ok {
+		ui.SetTheme(theme)
+	} else if themeerr == nil {
+		themeerr = fmt.Errorf("No such theme %q", config.Theme)
 	}
 	session, err := sp.NewSession(&sp.Config{
 		ApplicationKey:   appkey,
 		ApplicationName:  "Spot",
-		SettingsLocation: path.Join(usr.HomeDir, ".cache/spot"),
+		CacheLocation:    cachedir,
+		SettingsLocation: settingsdir,
 		AudioConsumer:    aw,
 	})
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	spot = SpotInit(nil, session, aw)
+	spot = SpotInit(session, aw, config)
+	if themeerr != nil {
+		spot.warn(themeerr.Error())
+	}
 	spot.redraw()
 	spot.run()
+	logger.Close() // Not deferred, so a crash can still log
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// Severity is how much a message matters, which decides how long it stays in
+// the cmdline and what colour it's drawn in
+type Severity int
+
+const (
+	Info    Severity = iota // Goes away after infoTimeout
+	Warning                 // Goes away after warningTimeout
+	Error                   // Stays until dismissed with Esc or replaced
+)
+
+var severityNames = map[Severity]string{
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+}
+
+var severityRoles = map[Severity]ui.Role{
+	Info:    ui.RoleNormal,
+	Warning: ui.RoleWarning,
+	Error:   ui.RoleError,
+}
+
+const (
+	infoTimeout    = 5 * time.Second
+	warningTimeout = 15 * time.Second
+	maxMessages    = 500 // Oldest messages are forgotten after this many
+)
+
+// A LogMessage is a message shown in the cmdline and kept in the MessageLog
+type LogMessage struct {
+	Time     time.Time
+	Severity Severity
+	Text     string
+}
+
+// expires returns when m should stop being shown, and false if it's sticky
+func (m *LogMessage) expires() (time.Time, bool) {
+	switch m.Severity {
+	case Info:
+		return m.Time.Add(infoTimeout), true
+	case Warning:
+		return m.Time.Add(warningTimeout), true
+	}
+	return time.Time{}, false
+}
+
+// MessageLog keeps every message posted, for :messages, and which of them is
+// showing in the cmdline
+type MessageLog struct {
+	Messages []LogMessage // Oldest first
+	Posted   int          // How many messages have ever been posted
+	showing  bool         // Whether the last message is still showing
+}
+
+// Post adds a message to the log and shows it in the cmdline
+func (l *MessageLog) Post(severity Severity, text string) {
+	l.Messages = append(l.Messages, LogMessage{time.Now(), severity, text})
+	if len(l.Messages) > maxMessages {
+		l.Messages = l.Messages[len(l.Messages)-maxMessages:]
+	}
+	l.Posted++
+	l.showing = true
+}
+
+// Current returns the message to show in the cmdline at now, or nil if there
+// isn't one or it has timed out
+func (l *MessageLog) Current(now time.Time) *LogMessage {
+	if !l.showing || len(l.Messages) == 0 {
+		return nil
+	}
+	m := &l.Messages[len(l.Messages)-1]
+	if expires, ok := m.expires(); ok && !now.Before(expires) {
+		l.showing = false
+		return nil
+	}
+	return m
+}
+
+// Expiring returns whether the message in the cmdline will time out, so the
+// cmdline needs redrawing every so often until it has
+func (l *MessageLog) Expiring() bool {
+	if m := l.Current(time.Now()); m != nil {
+		_, ok := m.expires()
+		return ok
+	}
+	return false
+}
+
+// Dismiss stops showing the current message
+func (l *MessageLog) Dismiss() {
+	l.showing = false
+}
+
+// status posts msg, the result of a command or action, unless it's empty
+func (g *Spot) status(msg string) {
+	if msg != "" {
+		g.messages.Post(Info, msg)
+	}
+}
+
+// warn posts msg as a warning, and logs it
+func (g *Spot) warn(msg string) {
+	uiLog.Warnf("%s", msg)
+	g.messages.Post(Warning, msg)
+}
+
+// fail posts msg as an error, which stays until it's dismissed, and logs it.
+// It returns an empty status, so commands and actions can return g.fail(...).
+func (g *Spot) fail(msg string) string {
+	uiLog.Errorf("%s", msg)
+	g.messages.Post(Error, msg)
+	return ""
+}
+
+// SpotScreenMessages lists every message posted, with when it was posted
+type SpotScreenMessages struct {
+	log  *MessageLog
+	sl   ui.ScrollList
+	seen int // log.Posted when the list was last updated
+}
+
+func NewSpotScreenMessages(log *MessageLog) SpotScreenMessages {
+	return SpotScreenMessages{log: log, sl: ui.NewScrollList()}
+}
+
+// update brings the list up to date with the log, following new messages if
+// the last one was selected
+func (s *SpotScreenMessages) update() {
+	if s.seen == s.log.Posted {
+		return
+	}
+	s.seen = s.log.Posted
+	following := s.sl.Selected >= len(s.sl.Items)-1
+	items := make([]ui.ListItem, len(s.log.Messages))
+	for i, m := range s.log.Messages {
+		items[i] = ui.ListItem{
+			TextL: m.Time.Format("15:04:05") + "  " + m.Text,
+			TextR: severityNames[m.Severity],
+		}
+	}
+	s.sl.SetItems(items)
+	if following {
+		s.sl.SelectBottom()
+	}
+}
+
+func (s *SpotScreenMessages) Draw(x, y, w, h int) {
+	s.update()
+	if len(s.sl.Items) == 0 {
+		dim := ui.StyleOf(ui.RoleDim)
+		ui.Printc(x+w/2, y+h/2, dim.Fg, dim.Bg, "No messages")
+		return
+	}
+	s.sl.Draw(x, y, w, h, true)
+}
+
+func (s *SpotScreenMessages) SetFilter(query string) {
+	s.sl.SetFilter(query)
+}
+
+func (s *SpotScreenMessages) Filter() string {
+	return s.sl.Filter()
+}
+
+func (s *SpotScreenMessages) Keys() []ui.KeyHelp {
+	return ui.ListKeys
+}
+
+func (s *SpotScreenMessages) HandleTBEvent(ev tb.Event) {
+	if ev.Type == tb.EventMouse {
+		s.sl.HandleMouse(ev)
+		return
+	}
+	s.sl.HandleKey(ev)
+}
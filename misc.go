@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"math"
-	"sync"
 	"time"
 )
 
@@ -12,29 +11,3 @@ import (
 func PrettyDuration(dur time.Duration) string {
 	return fmt.Sprintf("%d:%02d", int(dur.Minutes()), int(math.Mod(dur.Seconds(), 60)))
 }
-
-// A frankenstein synchronisation method. Like a wait group, but only with one or zero
-type Latch struct {
-	wg      sync.WaitGroup
-	latched bool
-}
-
-func (l *Latch) Set() {
-	if l.latched {
-		return
-	}
-	l.wg.Add(1)
-	l.latched = true
-}
-
-func (l *Latch) Clear() {
-	if !l.latched {
-		return
-	}
-	l.wg.Done()
-	l.latched = false
-}
-
-func (l *Latch) Wait() {
-	l.wg.Wait()
-}
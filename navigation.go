@@ -0,0 +1,169 @@
+package main
+
+import (
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// Names of the screens Spot registers at startup
+const (
+	screenAbout      = "about"
+	screenPlaylists  = "playlists"
+	screenEQ         = "eq"
+	screenVis        = "vis"
+	screenDiag       = "diag"
+	screenMessages   = "messages"
+	screenNowPlaying = "nowplaying"
+	screenLyrics     = "lyrics"
+)
+
+// Screens remembered for going back to, at most
+const maxHistory = 32
+
+// registeredScreen is a screen which can be shown by name
+type registeredScreen struct {
+	title  string
+	screen SpotScreen
+}
+
+// RegisterScreen makes screen available to ShowScreen under name. title is
+// shown in its tab, unless it is a TitledScreen.
+func (g *Spot) RegisterScreen(name, title string, screen SpotScreen) {
+	if g.screens == nil {
+		g.screens = make(map[string]registeredScreen)
+	}
+	g.screens[name] = registeredScreen{title, screen}
+}
+
+// Screen returns the screen registered under name, or nil
+func (g *Spot) Screen(name string) SpotScreen {
+	return g.screens[name].screen
+}
+
+// ShowScreen pushes the screen registered under name
+func (g *Spot) ShowScreen(name string) string {
+	screen := g.Screen(name)
+	if screen == nil {
+		return "No such screen " + name
+	}
+	g.Push(screen)
+	return ""
+}
+
+// Push shows screen, remembering the current screen to go back to. Going
+// forward is forgotten, as in a web browser. A screen is only ever in the
+// history once, so it has one tab.
+func (g *Spot) Push(screen SpotScreen) {
+	if screen == g.currentscreen {
+		return
+	}
+	g.history = append(removeScreen(g.history, screen), g.currentscreen)
+	if len(g.history) > maxHistory {
+		g.history = g.history[len(g.history)-maxHistory:]
+	}
+	g.forward = nil
+	g.currentscreen = screen
+}
+
+// Back goes back to the previous screen, returning false if there isn't one
+func (g *Spot) Back() bool {
+	if len(g.history) == 0 {
+		return false
+	}
+	g.forward = append(g.forward, g.currentscreen)
+	g.currentscreen = g.history[len(g.history)-1]
+	g.history = g.history[:len(g.history)-1]
+	return true
+}
+
+// Forward goes forward again to a screen gone back from, returning false if
+// there isn't one
+func (g *Spot) Forward() bool {
+	if len(g.forward) == 0 {
+		return false
+	}
+	g.history = append(g.history, g.currentscreen)
+	g.currentscreen = g.forward[len(g.forward)-1]
+	g.forward = g.forward[:len(g.forward)-1]
+	return true
+}
+
+// Tabs returns the open screens, in order: those to go back to, the current
+// screen, then those to go forward to. The current screen's index is current.
+func (g *Spot) Tabs() (tabs []SpotScreen, current int) {
+	tabs = append(tabs, g.history...)
+	current = len(tabs)
+	tabs = append(tabs, g.currentscreen)
+	for i := len(g.forward) - 1; i >= 0; i-- {
+		tabs = append(tabs, g.forward[i])
+	}
+	return
+}
+
+// GotoTab goes back or forward to the ith of Tabs
+func (g *Spot) GotoTab(i int) {
+	_, current := g.Tabs()
+	for ; i < current && g.Back(); current-- {
+	}
+	for ; i > current && g.Forward(); current++ {
+	}
+}
+
+// ScreenTitle returns the title shown in screen's tab
+func (g *Spot) ScreenTitle(screen SpotScreen) string {
+	if titled, ok := screen.(TitledScreen); ok {
+		return titled.Title()
+	}
+	for _, r := range g.screens {
+		if r.screen == screen {
+			return r.title
+		}
+	}
+	return "?"
+}
+
+// Widest a tab's title can be before it is cut short
+const maxTabWidth = 20
+
+// drawTabs draws a tab for each open screen in r, dropping tabs from the
+// start if they don't all fit. It remembers where each tab went, for clicks.
+func (g *Spot) drawTabs(r ui.Rect) {
+	tabs, current := g.Tabs()
+	titles := make([]string, len(tabs))
+	width := 0
+	for i, screen := range tabs {
+		titles[i] = " " + ui.Truncate(g.ScreenTitle(screen), maxTabWidth) + " "
+		width += ui.StringWidth(titles[i])
+	}
+	first := 0
+	for width > r.W && first < current {
+		width -= ui.StringWidth(titles[first])
+		first++
+	}
+	bar, selected := ui.StyleOf(ui.RoleBar), ui.StyleOf(ui.RoleSelectedFocus)
+	g.tabrects = make([]ui.Rect, len(tabs))
+	x := r.X
+	for i := first; i < len(tabs); i++ {
+		w := ui.StringWidth(titles[i])
+		if x+w > r.X+r.W {
+			break
+		}
+		style := bar
+		if i == current {
+			style = selected
+		}
+		ui.Print(x, r.Y, style.Fg, style.Bg, titles[i])
+		g.tabrects[i] = ui.Rect{X: x, Y: r.Y, W: w, H: 1}
+		x += w
+	}
+}
+
+// removeScreen returns screens without screen
+func removeScreen(screens []SpotScreen, screen SpotScreen) []SpotScreen {
+	kept := screens[:0]
+	for _, s := range screens {
+		if s != screen {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
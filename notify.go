@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/godbus/dbus"
+)
+
+// NotifyConfig holds the settings for desktop notifications. Summary and Body
+// are text/template templates, given the fields of SpotPlayer.NowPlaying,
+// e.g. {{.track}}, {{.artist}} and {{.album}}.
+type NotifyConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Summary     string `json:"summary"`
+	Body        string `json:"body"`
+	Art         bool   `json:"art"`          // Show the album cover
+	WhenFocused bool   `json:"when_focused"` // Notify even when spot's terminal has focus
+}
+
+func DefaultNotifyConfig() NotifyConfig {
+	return NotifyConfig{
+		Summary: "{{.track}}",
+		Body:    "{{.artist}}\n{{.album}}",
+		Art:     true,
+	}
+}
+
+const (
+	notifyName  = "org.freedesktop.Notifications"
+	notifyPath  = "/org/freedesktop/Notifications"
+	notifyAppID = "Spot"
+)
+
+// Notifier shows desktop notifications through the freedesktop Notifications
+// D-Bus interface. Each notification replaces the last, so they don't pile up.
+type Notifier struct {
+	obj     dbus.BusObject
+	summary *template.Template
+	body    *template.Template
+	mu      sync.Mutex
+	lastid  uint32
+}
+
+// NewNotifier returns a Notifier which notifies over conn, usually the
+// session bus but any bus with a notification server will do, such as a
+// private one for testing. It fails if either template won't parse.
+func NewNotifier(conn *dbus.Conn, summary, body string) (*Notifier, error) {
+	s, err := template.New("summary").Option("missingkey=zero").Parse(summary)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.New("body").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{obj: conn.Object(notifyName, notifyPath), summary: s, body: b}, nil
+}
+
+// Notify shows a notification of fields, with the image at icon if it isn't
+// empty
+func (n *Notifier) Notify(fields map[string]string, icon string) error {
+	var summary, body bytes.Buffer
+	if err := n.summary.Execute(&summary, fields); err != nil {
+		return err
+	}
+	if err := n.body.Execute(&body, fields); err != nil {
+		return err
+	}
+	hints := map[string]dbus.Variant{"category": dbus.MakeVariant("x-spot.track")}
+	if icon != "" {
+		hints["image-path"] = dbus.MakeVariant("file://" + icon)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var id uint32
+	err := n.obj.Call(notifyName+".Notify", 0, notifyAppID, n.lastid, icon,
+		summary.String(), body.String(), []string{}, hints, int32(-1)).Store(&id)
+	if err != nil {
+		return err
+	}
+	n.lastid = id
+	return nil
+}
+
+// terminalFocused returns whether the terminal spot is running in has focus.
+// It goes by the terminal's focus reports, if it sends them. Otherwise it can
+// only tell under X, where the terminal sets $WINDOWID and xdotool is
+// installed, and assumes the terminal doesn't have focus.
+func terminalFocused() bool {
+	if focused, reported := reportedFocus(); reported {
+		return focused
+	}
+	window := os.Getenv("WINDOWID")
+	if window == "" {
+		return false
+	}
+	out, err := exec.Command("xdotool", "getactivewindow").Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) == window
+}
+
+// notifytrack notifies the user of the track that has just started playing,
+// if notifications are on. It connects to the session bus the first time,
+// and gives up if it can't.
+func (g *Spot) notifytrack() {
+	config := g.config.Notify
+	if !config.Enabled || g.nonotify || g.Player.track == nil {
+		return
+	}
+	if g.notifier == nil {
+		conn, err := dbus.SessionBus()
+		if err == nil {
+			g.notifier, err = NewNotifier(conn, config.Summary, config.Body)
+		}
+		if err != nil {
+			g.warn("Can't notify: " + err.Error())
+			g.nonotify = true
+			return
+		}
+	}
+	fields := g.Player.NowPlaying()
+	album := g.Player.track.Album()
+	notifier := g.notifier
+	// Talking to D-Bus, and fetching the cover, can take a while
+	go func() {
+		defer recoverCrash("notify")
+		if !config.WhenFocused && terminalFocused() {
+			return
+		}
+		var icon string
+		if config.Art {
+			var err error
+			if icon, err = coverPath(album); err != nil {
+				uiLog.Debugf("No cover for the notification: %s", err)
+			}
+		}
+		if err := notifier.Notify(fields, icon); err != nil {
+			uiLog.Warnf("Notifying failed: %s", err)
+		}
+	}()
+}
+
+// notifycommand handles the :notify command, which turns notifications on or
+// off and saves the setting
+func (g *Spot) notifycommand(args []string) string {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		state := "off"
+		if g.config.Notify.Enabled {
+			state = "on"
+		}
+		return "Notifications are " + state + ". Usage: :notify on|off"
+	}
+	g.config.Notify.Enabled = args[0] == "on"
+	g.nonotify = false // Give it another go
+	if err := g.config.Save(); err != nil {
+		return g.fail("Couldn't save notification settings: " + err.Error())
+	}
+	return "Notifications " + args[0]
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus"
+)
+
+// notification is a call to fakeNotifications.Notify
+type notification struct {
+	replaces      uint32
+	icon          string
+	summary, body string
+	hints         map[string]dbus.Variant
+}
+
+// fakeNotifications is a notification server which records what it's asked
+// to show
+type fakeNotifications struct {
+	mu     sync.Mutex
+	nextid uint32
+	shown  []notification
+}
+
+func (f *fakeNotifications) Notify(app string, replaces uint32, icon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shown = append(f.shown, notification{replaces, icon, summary, body, hints})
+	if replaces != 0 {
+		return replaces, nil
+	}
+	f.nextid++
+	return f.nextid, nil
+}
+
+// privateBus starts a dbus-daemon of its own, skipping the test if it can't,
+// and returns its address
+func privateBus(t *testing.T) string {
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	out, err := daemon.StdoutPipe()
+	if err == nil {
+		err = daemon.Start()
+	}
+	if err != nil {
+		t.Skipf("Can't start dbus-daemon: %s", err)
+	}
+	t.Cleanup(func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+	})
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := out.Read(b); err != nil {
+			t.Skipf("dbus-daemon didn't give its address: %s", err)
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	return strings.TrimSpace(string(line))
+}
+
+func dialBus(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Dial(address)
+	if err == nil {
+		if err = conn.Auth(nil); err == nil {
+			err = conn.Hello()
+		}
+	}
+	if err != nil {
+		t.Fatalf("Connecting to %s: %s", address, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// serveNotifications exports a fakeNotifications on a private bus, and
+// returns it and a connection for a Notifier to use
+func serveNotifications(t *testing.T) (*fakeNotifications, *dbus.Conn) {
+	address := privateBus(t)
+	server := dialBus(t, address)
+	fake := &fakeNotifications{}
+	if err := server.Export(fake, notifyPath, notifyName); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := server.RequestName(notifyName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("Can't own %s: %v", notifyName, err)
+	}
+	return fake, dialBus(t, address)
+}
+
+var testFields = map[string]string{"track": "Hyperballad", "artist": "Björk", "album": "Post"}
+
+func TestNotifyTemplates(t *testing.T) {
+	fake, conn := serveNotifications(t)
+	tests := []struct {
+		summary, body         string
+		wantsummary, wantbody string
+	}{
+		{DefaultNotifyConfig().Summary, DefaultNotifyConfig().Body, "Hyperballad", "Björk\nPost"},
+		{"{{.artist}} – {{.track}}", "", "Björk – Hyperballad", ""},
+		{"{{.track}}", "{{.label}}", "Hyperballad", ""}, // Unknown fields are empty
+	}
+	for _, test := range tests {
+		n, err := NewNotifier(conn, test.summary, test.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Notify(testFields, ""); err != nil {
+			t.Fatal(err)
+		}
+		fake.mu.Lock()
+		got := fake.shown[len(fake.shown)-1]
+		fake.mu.Unlock()
+		if got.summary != test.wantsummary || got.body != test.wantbody {
+			t.Errorf("%q and %q notified %q and %q, want %q and %q", test.summary, test.body,
+				got.summary, got.body, test.wantsummary, test.wantbody)
+		}
+	}
+}
+
+func TestNotifyBadTemplate(t *testing.T) {
+	if _, err := NewNotifier(nil, "{{.track", ""); err == nil {
+		t.Error("NewNotifier with an unclosed action succeeded")
+	}
+}
+
+func TestNotifyReplaces(t *testing.T) {
+	fake, conn := serveNotifications(t)
+	n, err := NewNotifier(conn, "{{.track}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := n.Notify(testFields, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A second Notifier starts afresh
+	other, _ := NewNotifier(conn, "{{.track}}", "")
+	if err := other.Notify(testFields, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{0, 1, 1, 0}
+	if len(fake.shown) != len(want) {
+		t.Fatalf("%d notifications shown, want %d", len(fake.shown), len(want))
+	}
+	for i, got := range fake.shown {
+		if got.replaces != want[i] {
+			t.Errorf("Notification %d replaced %d, want %d", i, got.replaces, want[i])
+		}
+	}
+	if n.lastid != 1 || other.lastid != 2 {
+		t.Errorf("lastid = %d and %d, want 1 and 2", n.lastid, other.lastid)
+	}
+}
+
+func TestNotifyImage(t *testing.T) {
+	fake, conn := serveNotifications(t)
+	n, err := NewNotifier(conn, "{{.track}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testFields, "")
+	n.Notify(testFields, "/tmp/cover.jpg")
+	noicon, icon := fake.shown[0], fake.shown[1]
+	if _, ok := noicon.hints["image-path"]; ok || noicon.icon != "" {
+		t.Errorf("Notification without a cover had icon %q and hints %v", noicon.icon, noicon.hints)
+	}
+	if path, _ := icon.hints["image-path"].Value().(string); path != "file:///tmp/cover.jpg" {
+		t.Errorf("image-path hint = %q, want %q", path, "file:///tmp/cover.jpg")
+	}
+	if icon.icon != "/tmp/cover.jpg" {
+		t.Errorf("Icon = %q, want %q", icon.icon, "/tmp/cover.jpg")
+	}
+	if category, _ := icon.hints["category"].Value().(string); category != "x-spot.track" {
+		t.Errorf("category hint = %q, want x-spot.track", category)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // Covers are JPEGs
+	_ "image/png"
+	"io"
+	"os"
+	"sync"
+
+	tb "github.com/nsf/termbox-go"
+	sp "github.com/op/go-libspotify/spotify"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// A GraphicScreen shows images with a terminal graphics protocol. They're
+// written straight to the terminal once termbox has drawn everything else.
+type GraphicScreen interface {
+	SpotScreen
+	DrawGraphics(w io.Writer) // Places its images, if they've moved or changed
+	ClearGraphics(w io.Writer)
+}
+
+// SpotScreenNowPlaying shows the album cover of the playing track, with the
+// track's details and what's queued up next beside it
+type SpotScreenNowPlaying struct {
+	player   *SpotPlayer
+	art      bool // Whether to show the cover at all
+	graphics ui.Graphics
+	loaded   chan struct{} // Sent on when a cover has loaded, to redraw
+
+	mu      sync.Mutex // Guards track and picture, which covers load into
+	track   *sp.Track  // The track whose cover is loaded, or loading
+	picture *ui.Picture
+
+	artrect ui.Rect     // Where the cover was last drawn
+	placed  ui.Rect     // Where the cover was last placed with graphics, if it was
+	shown   *ui.Picture // The cover placed there
+}
+
+// NewSpotScreenNowPlaying returns the now playing screen for player. art is
+// the album_art setting: "off", "auto", or one of ui.ParseGraphics' names.
+func NewSpotScreenNowPlaying(player *SpotPlayer, art string) *SpotScreenNowPlaying {
+	s := &SpotScreenNowPlaying{player: player, art: art != "off", loaded: make(chan struct{}, 1)}
+	if s.art {
+		graphics, err := ui.ParseGraphics(art)
+		if err != nil {
+			uiLog.Warnf("%s, using blocks", err)
+		}
+		s.graphics = graphics
+	}
+	return s
+}
+
+// Loaded is sent on when a cover has loaded and the screen needs redrawing
+func (s *SpotScreenNowPlaying) Loaded() <-chan struct{} {
+	return s.loaded
+}
+
+// cover returns the picture of the playing track's cover, starting to load
+// it if it's a different track from last time. It returns nil until it has
+// loaded, or if it won't.
+func (s *SpotScreenNowPlaying) cover() *ui.Picture {
+	track := s.player.track
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sameTrack(track, s.track) {
+		return s.picture
+	}
+	s.track, s.picture = track, nil
+	go func() {
+		defer recoverCrash("cover")
+		picture, err := loadCover(track.Album())
+		if err != nil {
+			uiLog.Debugf("No cover for the now playing screen: %s", err)
+			return
+		}
+		s.mu.Lock()
+		if sameTrack(s.track, track) {
+			s.picture = picture
+		}
+		s.mu.Unlock()
+		select {
+		case s.loaded <- struct{}{}:
+		default: // A redraw is already due
+		}
+	}()
+	return nil
+}
+
+// loadCover fetches and decodes album's cover
+func loadCover(album *sp.Album) (*ui.Picture, error) {
+	p, err := coverPath(album)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return ui.NewPicture(img), nil
+}
+
+func (s *SpotScreenNowPlaying) Draw(x, y, w, h int) {
+	normal, dim := ui.StyleOf(ui.RoleNormal), ui.StyleOf(ui.RoleDim)
+	s.artrect = ui.Rect{}
+	p := s.player
+	if p.playstate == Ejected || p.track == nil {
+		ui.Printc(x+w/2, y+h/2, dim.Fg, dim.Bg, "Nothing playing")
+		return
+	}
+
+	// The cover takes the left of the screen, as near square as the terminal
+	// allows, leaving at least half the width for the details
+	textx := x + 1
+	if s.art {
+		if picture := s.cover(); picture != nil {
+			box := ui.Rect{X: x + 1, Y: y + 1, W: w/2 - 2, H: h - 2}
+			aw, ah := picture.Fit(box.W, box.H)
+			s.artrect = ui.Rect{X: box.X, Y: box.Y + (box.H-ah)/2, W: aw, H: ah}
+			if s.graphics == ui.GraphicsBlocks {
+				picture.Draw(s.artrect)
+			}
+			textx = s.artrect.X + s.artrect.W + 2
+		}
+	}
+	textw := x + w - textx - 1
+	if textw <= 0 {
+		return
+	}
+
+	np := p.NowPlaying()
+	accent, meter := ui.StyleOf(ui.RoleAccent), ui.StyleOf(ui.RoleMeter)
+	lines := []struct {
+		text  string
+		style ui.Style
+	}{
+		{np["track"], accent},
+		{np["artist"], normal},
+		{np["album"], dim},
+	}
+	texty := y + 1
+	for _, line := range lines {
+		ui.Printlim(textx, texty, line.style.Fg, line.style.Bg, line.text, textw)
+		texty++
+	}
+
+	texty++
+	progress := fmt.Sprintf("%s %s/%s", PlayerstateSymbols[p.playstate], np["elapsed"], np["duration"])
+	ui.Printlim(textx, texty, normal.Fg, normal.Bg, progress, textw)
+	if barw := textw - ui.StringWidth(progress) - 1; barw > 0 && p.track.Duration() > 0 {
+		frac := float64(p.elapsed) / float64(p.track.Duration())
+		ui.Drawhmeter(textx+ui.StringWidth(progress)+1, texty, barw, frac, meter.Fg)
+	}
+
+	texty += 2
+	if len(p.queue) == 0 || texty >= y+h {
+		return
+	}
+	ui.Printlim(textx, texty, normal.Fg, normal.Bg, "Up next:", textw)
+	texty++
+	for i := 0; i < len(p.queue) && texty < y+h; i, texty = i+1, texty+1 {
+		next := p.queue[i].Name() + " - " + ArtistNames(p.queue[i])
+		ui.Printlim(textx, texty, dim.Fg, dim.Bg, next, textw)
+	}
+}
+
+// DrawGraphics places the cover with the terminal's graphics protocol, when
+// it isn't drawn with blocks. It's only sent when it moves or changes, as
+// sending an image is slow.
+func (s *SpotScreenNowPlaying) DrawGraphics(w io.Writer) {
+	if !s.art || s.graphics == ui.GraphicsBlocks {
+		return
+	}
+	s.mu.Lock()
+	picture := s.picture
+	s.mu.Unlock()
+	if s.artrect == (ui.Rect{}) {
+		picture = nil
+	}
+	if s.artrect == s.placed && picture == s.shown {
+		return
+	}
+	s.ClearGraphics(w)
+	if picture == nil {
+		return
+	}
+	if err := ui.PlaceImage(w, s.artrect, picture.Image(), s.graphics); err != nil {
+		uiLog.Warnf("Couldn't show the cover: %s", err)
+		return
+	}
+	s.placed, s.shown = s.artrect, picture
+}
+
+// ClearGraphics removes the cover placed by DrawGraphics, so it's placed
+// again next time. Sixels are part of the text, so they're only gone once
+// termbox has redrawn it all.
+func (s *SpotScreenNowPlaying) ClearGraphics(w io.Writer) {
+	if s.shown == nil {
+		return
+	}
+	ui.ClearImages(w, s.graphics)
+	if s.graphics == ui.GraphicsSixel {
+		tb.Sync()
+	}
+	s.placed, s.shown = ui.Rect{}, nil
+}
+
+func (s *SpotScreenNowPlaying) HandleTBEvent(tb.Event) {
+}
+
+// drawgraphics has the current screen place its images after termbox has
+// drawn, or clears them away when it's been left or something's over it
+func (g *Spot) drawgraphics() {
+	screen, _ := g.currentscreen.(GraphicScreen)
+	if g.help != nil || g.dialog != nil {
+		screen = nil
+	}
+	if g.graphics != nil && g.graphics != screen {
+		g.graphics.ClearGraphics(os.Stdout)
+	}
+	g.graphics = screen
+	if screen != nil {
+		screen.DrawGraphics(os.Stdout)
+	}
+}
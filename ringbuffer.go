@@ -0,0 +1,64 @@
+package main
+
+// ringBuffer is a fixed size circular byte buffer. It does no locking of its
+// own; callers are expected to guard it (AudioWriter does so with its mutex).
+type ringBuffer struct {
+	data  []byte
+	start int // Index of the oldest buffered byte
+	n     int // Number of bytes currently buffered
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, size)}
+}
+
+// Len returns the number of bytes waiting to be read
+func (r *ringBuffer) Len() int {
+	return r.n
+}
+
+// Cap returns the total size of the buffer in bytes
+func (r *ringBuffer) Cap() int {
+	return len(r.data)
+}
+
+// Free returns the number of bytes that can be written before the buffer is full
+func (r *ringBuffer) Free() int {
+	return len(r.data) - r.n
+}
+
+// Write copies as much of p into the buffer as will fit, returning the number
+// of bytes written. It never overwrites unread data.
+func (r *ringBuffer) Write(p []byte) int {
+	if len(p) > r.Free() {
+		p = p[:r.Free()]
+	}
+	end := (r.start + r.n) % len(r.data)
+	written := copy(r.data[end:], p)
+	if written < len(p) {
+		// Wrap around to the start of the backing slice
+		written += copy(r.data, p[written:])
+	}
+	r.n += written
+	return written
+}
+
+// Read copies up to len(p) buffered bytes into p, returning the number copied
+func (r *ringBuffer) Read(p []byte) int {
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	read := copy(p, r.data[r.start:])
+	if read < len(p) {
+		read += copy(p[read:], r.data)
+	}
+	r.start = (r.start + read) % len(r.data)
+	r.n -= read
+	return read
+}
+
+// Reset discards all buffered data
+func (r *ringBuffer) Reset() {
+	r.start = 0
+	r.n = 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRingBufferWraparound(t *testing.T) {
+	r := newRingBuffer(8)
+	if n := r.Write([]byte("abcdef")); n != 6 {
+		t.Fatalf("Write = %d, want 6", n)
+	}
+	p := make([]byte, 4)
+	if n := r.Read(p); n != 4 || string(p) != "abcd" {
+		t.Fatalf("Read = %d %q, want 4 \"abcd\"", n, p)
+	}
+	// This write runs off the end of the backing slice and wraps to the start
+	if n := r.Write([]byte("ghijkl")); n != 6 {
+		t.Fatalf("Write = %d, want 6", n)
+	}
+	if r.Len() != 8 || r.Free() != 0 {
+		t.Fatalf("Len, Free = %d, %d, want 8, 0", r.Len(), r.Free())
+	}
+	p = make([]byte, 10)
+	n := r.Read(p)
+	if want := "efghijkl"; n != len(want) || string(p[:n]) != want {
+		t.Fatalf("Read = %d %q, want %d %q", n, p[:n], len(want), want)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("Len = %d after reading everything, want 0", r.Len())
+	}
+}
+
+func TestRingBufferPartialWrite(t *testing.T) {
+	tests := []struct {
+		size, buffered, write, want int
+	}{
+		{8, 0, 4, 4},
+		{8, 0, 8, 8},
+		{8, 0, 12, 8},
+		{8, 5, 4, 3},
+		{8, 8, 1, 0},
+		{8, 3, 0, 0},
+	}
+	for _, test := range tests {
+		r := newRingBuffer(test.size)
+		r.Write(make([]byte, test.buffered))
+		if n := r.Write(bytes.Repeat([]byte{1}, test.write)); n != test.want {
+			t.Errorf("%d byte buffer holding %d: Write of %d = %d, want %d",
+				test.size, test.buffered, test.write, n, test.want)
+		}
+		if r.Len() != test.buffered+test.want {
+			t.Errorf("%d byte buffer holding %d: Len = %d after writing %d, want %d",
+				test.size, test.buffered, r.Len(), test.write, test.buffered+test.want)
+		}
+	}
+}
+
+func TestRingBufferNeverOverwrites(t *testing.T) {
+	r := newRingBuffer(4)
+	r.Write([]byte("abcd"))
+	if n := r.Write([]byte("xy")); n != 0 {
+		t.Fatalf("Write to a full buffer = %d, want 0", n)
+	}
+	p := make([]byte, 4)
+	if r.Read(p); string(p) != "abcd" {
+		t.Fatalf("Read %q, want the original \"abcd\"", p)
+	}
+}
+
+func TestRingBufferReset(t *testing.T) {
+	r := newRingBuffer(4)
+	r.Write([]byte("abc"))
+	r.Read(make([]byte, 2))
+	r.Reset()
+	if r.Len() != 0 || r.Free() != 4 {
+		t.Fatalf("Len, Free = %d, %d after Reset, want 0, 4", r.Len(), r.Free())
+	}
+	if n := r.Read(make([]byte, 4)); n != 0 {
+		t.Fatalf("Read = %d after Reset, want 0", n)
+	}
+}
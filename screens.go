@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strings"
 
 	tb "github.com/nsf/termbox-go"
@@ -13,18 +17,47 @@ type SpotScreen interface {
 	HandleTBEvent(ev tb.Event)
 }
 
+// A FilterableScreen has a list which can be narrowed down by typing a filter
+// after pressing /
+type FilterableScreen interface {
+	SpotScreen
+	SetFilter(query string)
+	Filter() string
+}
+
+// A TitledScreen has a title for its tab in the top bar. Screens without one
+// use the title they were registered with.
+type TitledScreen interface {
+	SpotScreen
+	Title() string
+}
+
+// A TrackScreen has a list of tracks which track commands, such as :add and
+// :star, act on when it has focus
+type TrackScreen interface {
+	SpotScreen
+	FocussedTracks() *TrackList // nil if no track list has focus
+}
+
+// A HelpfulScreen lists the keys it handles, for help
+type HelpfulScreen interface {
+	SpotScreen
+	Keys() []ui.KeyHelp
+}
+
 type SpotScreenAbout struct{}
 
 func (SpotScreenAbout) Draw(_, _, w, _ int) {
-	ui.Printc(w/2, 5, tb.ColorGreen, tb.ColorDefault, `                     __ `)
-	ui.Printc(w/2, 6, tb.ColorGreen, tb.ColorDefault, `   _________  ____  / /_`)
-	ui.Printc(w/2, 7, tb.ColorGreen, tb.ColorDefault, `  / ___/ __ \/ __ \/ __/`)
-	ui.Printc(w/2, 8, tb.ColorGreen, tb.ColorDefault, ` (__  ) /_/ / /_/ / /_  `)
-	ui.Printc(w/2, 9, tb.ColorGreen, tb.ColorDefault, `/____/ .___/\____/\__/  `)
-	ui.Printc(w/2, 10, tb.ColorGreen, tb.ColorDefault, `    /_/                 `)
-	ui.Printc(w/2, 12, tb.ColorWhite, tb.ColorDefault, "Welcome to Spot "+version)
-	ui.Printc(w/2, 13, tb.ColorWhite, tb.ColorDefault, "A simple, fast command line Spotify Client")
-	ui.Printc(w/2, 15, tb.ColorWhite, tb.ColorDefault, "Spot uses vim-like commands. Use the source for now.")
+	logo, normal := ui.StyleOf(ui.RoleLogo), ui.StyleOf(ui.RoleNormal)
+	ui.Printc(w/2, 5, logo.Fg, logo.Bg, `                     __ `)
+	ui.Printc(w/2, 6, logo.Fg, logo.Bg, `   _________  ____  / /_`)
+	ui.Printc(w/2, 7, logo.Fg, logo.Bg, `  / ___/ __ \/ __ \/ __/`)
+	ui.Printc(w/2, 8, logo.Fg, logo.Bg, ` (__  ) /_/ / /_/ / /_  `)
+	ui.Printc(w/2, 9, logo.Fg, logo.Bg, `/____/ .___/\____/\__/  `)
+	ui.Printc(w/2, 10, logo.Fg, logo.Bg, `    /_/                 `)
+	ui.Printc(w/2, 12, normal.Fg, normal.Bg, "Welcome to Spot "+version)
+	ui.Printc(w/2, 13, normal.Fg, normal.Bg, "A simple, fast command line Spotify Client")
+	ui.Printc(w/2, 15, normal.Fg, normal.Bg, "Spot uses vim-like keys and commands. Press ? for help.")
 }
 
 func (SpotScreenAbout) HandleTBEvent(tb.Event) {
@@ -36,146 +69,899 @@ type SpotScreenPlaylists struct {
 	playlistsSL     ui.ScrollList
 	tracksfocussed  bool // if false, playlist list is focussed
 	playlistchanged bool // flag to trigger load of new playlist
+	edits           []playlistEdit
+	collapsed       map[uint64]bool // Folders, by id, whose contents are hidden
+	split           *ui.Split       // The playlist and track panes, side by side
+	panes           []ui.Rect       // Where the panes were last drawn
+}
+
+// The panes of the playlists screen's split
+const (
+	playlistsPane = iota
+	tracksPane
+)
+
+// playlistEdit records a change made to a playlist, so it can be undone
+type playlistEdit struct {
+	desc string // What was done, for the status line
+	undo func() error
 }
 
 // TODO: some form of asynchronous loading that doesn't block the main thread
 // When I tested this (on a slowish connection) there were significant pauses where
 // (I assume) the tracks were loading in a playlist.
-func NewSpotScreenPlaylists() SpotScreenPlaylists {
+func NewSpotScreenPlaylists(columns []string) SpotScreenPlaylists {
 	return SpotScreenPlaylists{
 		playlistsSL:     ui.NewScrollList(),
-		tracksSL:        NewTrackList(),
+		tracksSL:        NewTrackList(columns),
 		playlistchanged: true,
+		collapsed:       make(map[uint64]bool),
+		split:           ui.NewSplit(ui.Horizontal, true, ui.Fixed(30).AtLeast(12), ui.Flex().AtLeast(20)),
 	}
 }
 
 func (s *SpotScreenPlaylists) Draw(x, y, w, h int) {
 	if s.playlists == nil {
-		ui.Printc((x+w)/2, 10, tb.ColorWhite, tb.ColorDefault, "Login to view playlists")
+		normal := ui.StyleOf(ui.RoleNormal)
+		ui.Printc((x+w)/2, 10, normal.Fg, normal.Bg, "Login to view playlists")
+	}
+	s.refreshPlaylists()
+	if s.playlistchanged && len(s.playlistsSL.Items) > 0 {
+		playlist, err := s.playlistAt(s.selectedIndex())
+		if err != nil {
+			spot.fail(err.Error())
+		} else if playlist != nil {
+			playlist.Wait()
+			s.tracksSL.SetPlaylist(playlist)
+		}
+		s.playlistchanged = false
+	}
+	panes, ok := s.split.Layout(ui.Rect{X: x, Y: y, W: w, H: h})
+	if !ok {
+		ui.DrawTooSmall(ui.Rect{X: x, Y: y, W: w, H: h})
+		return
+	}
+	s.panes = panes
+	if p := panes[playlistsPane]; !s.split.Panes[playlistsPane].Hidden {
+		s.playlistsSL.Draw(p.X, p.Y, p.W, p.H, !s.tracksfocussed)
+	}
+	s.split.DrawDividers(panes)
+	p := panes[tracksPane]
+	s.tracksSL.Draw(p.X, p.Y, p.W, p.H, s.tracksfocussed)
+}
+
+// ResizePlaylists makes the playlist pane delta columns wider
+func (s *SpotScreenPlaylists) ResizePlaylists(delta int) {
+	s.split.Resize(playlistsPane, delta)
+}
+
+// TogglePlaylists hides the playlist pane, leaving more room for the
+// tracks, or shows it again
+func (s *SpotScreenPlaylists) TogglePlaylists() {
+	s.split.Toggle(playlistsPane)
+	s.tracksfocussed = s.tracksfocussed || s.split.Panes[playlistsPane].Hidden
+}
+
+// Data values of the items pinned to the top of the playlist pane, which
+// aren't in the playlist container
+const (
+	starredItem = -1
+	inboxItem   = -2
+)
+
+// refreshPlaylists rebuilds the playlist pane's items from the container.
+// Each item's Data is its index in the container, apart from the pinned
+// Starred and Inbox items.
+func (s *SpotScreenPlaylists) refreshPlaylists() {
+	playlistlist := []ui.ListItem{
+		{TextL: "Starred", Data: starredItem},
+		{TextL: "Inbox", Data: inboxItem},
 	}
-	var playlistlist []ui.ListItem
 	indent := 0
+	hidden := 0 // Depth of folders we're inside of which are collapsed
 	for i := 0; i < s.playlists.Playlists(); i++ {
 		// This is a little fiddly, we have to deal with playlist
 		// folders as well as regular playlists
 		switch s.playlists.PlaylistType(i) {
 		case sp.PlaylistTypePlaylist:
-			playlistlist = append(playlistlist, ui.ListItem{strings.Repeat(" ", indent) + s.playlists.Playlist(i).Name(), "", i, false})
+			if hidden == 0 {
+				playlistlist = append(playlistlist, ui.ListItem{TextL: strings.Repeat(" ", indent) + s.playlists.Playlist(i).Name(), Data: i})
+			}
 		case sp.PlaylistTypeStartFolder:
 			folder, _ := s.playlists.Folder(i)
-			playlistlist = append(playlistlist, ui.ListItem{strings.Repeat(" ", indent) + folder.Name(), "", i, false})
+			if hidden > 0 || s.collapsed[folder.Id()] {
+				if hidden == 0 {
+					playlistlist = append(playlistlist, ui.ListItem{TextL: strings.Repeat(" ", indent) + "▸" + folder.Name(), Data: i})
+				}
+				hidden++
+			} else {
+				playlistlist = append(playlistlist, ui.ListItem{TextL: strings.Repeat(" ", indent) + "▾" + folder.Name(), Data: i})
+			}
 			indent++
 		case sp.PlaylistTypeEndFolder:
 			indent--
+			if hidden > 0 {
+				hidden--
+			}
 		}
 	}
-	s.playlistsSL.Items = playlistlist
-	if s.playlistchanged {
-		switch s.playlists.PlaylistType(s.playlistsSL.Items[s.playlistsSL.Selected].Data) {
-		case sp.PlaylistTypePlaylist:
-			playlist := s.playlists.Playlist(s.playlistsSL.Items[s.playlistsSL.Selected].Data)
-			playlist.Wait()
-			s.tracksSL.SetPlaylist(playlist)
+	s.playlistsSL.SetItems(playlistlist)
+	if s.playlistsSL.Selected >= len(playlistlist) {
+		s.playlistsSL.Selected = len(playlistlist) - 1
+	}
+	if s.playlistsSL.Selected < 0 {
+		s.playlistsSL.Selected = 0
+	}
+}
+
+// selectedIndex returns the container index of the selected playlist pane
+// item, or the Data of a pinned item
+func (s *SpotScreenPlaylists) selectedIndex() int {
+	return s.playlistsSL.Items[s.playlistsSL.Selected].Data
+}
+
+// selectedInContainer returns whether the selected item is one of the user's
+// own playlists or folders, rather than a pinned item, and isn't hidden by
+// the filter
+func (s *SpotScreenPlaylists) selectedInContainer() bool {
+	return s.playlistsSL.SelectionShown() && s.selectedIndex() >= 0
+}
+
+// playlistAt returns the playlist for a playlist pane item's Data, or nil if
+// it is a folder
+func (s *SpotScreenPlaylists) playlistAt(index int) (*sp.Playlist, error) {
+	switch {
+	case index == starredItem:
+		return spot.session.Starred()
+	case index == inboxItem:
+		return spot.session.Inbox()
+	case s.playlists.PlaylistType(index) == sp.PlaylistTypePlaylist:
+		return s.playlists.Playlist(index), nil
+	}
+	return nil, nil
+}
+
+// selectIndex selects the playlist pane item at container index i, if visible
+func (s *SpotScreenPlaylists) selectIndex(i int) {
+	s.refreshPlaylists()
+	for n, item := range s.playlistsSL.Items {
+		if item.Data == i {
+			s.playlistsSL.Selected = n
 		}
-		s.playlistchanged = false
 	}
-	s.playlistsSL.Draw(x, y, 30, h, !s.tracksfocussed)
-	ui.Drawbox(x+30, y, 1, h, "") // Dividing line
-	s.tracksSL.Draw(x+31, y, w-31, h, s.tracksfocussed)
+	s.playlistchanged = true
+}
+
+// SetFilter narrows down whichever of the playlist and track lists has focus
+func (s *SpotScreenPlaylists) SetFilter(query string) {
+	if s.tracksfocussed {
+		s.tracksSL.sl.SetFilter(query)
+		return
+	}
+	selected := s.playlistsSL.Selected
+	s.playlistsSL.SetFilter(query)
+	if s.playlistsSL.Selected != selected {
+		s.playlistchanged = true
+	}
+}
+
+// Filter returns the filter of whichever list has focus
+func (s *SpotScreenPlaylists) Filter() string {
+	if s.tracksfocussed {
+		return s.tracksSL.sl.Filter()
+	}
+	return s.playlistsSL.Filter()
+}
+
+// keys are the keys for whichever pane has focus, besides its list's own
+func (s *SpotScreenPlaylists) keys() []keybinding {
+	keys := []keybinding{
+		{keys: []tb.Key{tb.KeyTab}, name: "tab", help: "Switch between playlists and tracks", run: func(*Spot) {
+			s.tracksfocussed = !s.tracksfocussed || s.split.Panes[playlistsPane].Hidden
+		}},
+		{ch: '<', name: "<", help: "Make the playlists narrower, wider", run: func(*Spot) {
+			s.ResizePlaylists(-2)
+		}},
+		{ch: '>', name: ">", help: "Make the playlists narrower, wider", run: func(*Spot) {
+			s.ResizePlaylists(2)
+		}},
+		{ch: '|', name: "|", help: "Hide or show the playlists", run: func(*Spot) {
+			s.TogglePlaylists()
+		}},
+		{ch: 'u', name: "u", help: "Undo the last change to a playlist", run: func(g *Spot) {
+			g.status(s.Undo())
+		}},
+	}
+	if s.tracksfocussed {
+		return append(keys, s.trackKeys()...)
+	}
+	return append(keys, s.playlistKeys()...)
+}
+
+// playlistKeys are the keys for organising playlists, when the playlist pane
+// has focus. A and F were n and f, until n and N jumped between filter
+// matches in every list; f moved with n to keep the pair together.
+func (s *SpotScreenPlaylists) playlistKeys() []keybinding {
+	loaded := func(run func(g *Spot)) func(g *Spot) {
+		return func(g *Spot) {
+			if s.playlists != nil {
+				run(g)
+			}
+		}
+	}
+	return []keybinding{
+		{keys: []tb.Key{tb.KeyEnter}, name: "enter", help: "Open or close the folder", run: func(*Spot) {
+			s.ToggleFolder()
+		}},
+		{ch: 'A', name: "A", help: "New playlist, folder", run: loaded(func(*Spot) {
+			askPlaylistName("New playlist", "new", "")
+		})},
+		{ch: 'F', name: "F", help: "New playlist, folder", run: loaded(func(*Spot) {
+			askPlaylistName("New folder", "folder", "")
+		})},
+		{ch: 'r', name: "r", help: "Rename the playlist or folder", run: loaded(func(*Spot) {
+			if s.selectedInContainer() {
+				askPlaylistName("Rename", "rename", s.SelectedName())
+			}
+		})},
+		{ch: 'd', name: "d", help: "Delete the playlist or folder", run: loaded(func(g *Spot) {
+			g.status(g.docommand("playlist", []string{"delete"}))
+		})},
+		{ch: 'K', name: "K", help: "Move the playlist up, down", run: loaded(func(g *Spot) {
+			g.status(s.MoveSelected(-1))
+		})},
+		{ch: 'J', name: "J", help: "Move the playlist up, down", run: loaded(func(g *Spot) {
+			g.status(s.MoveSelected(1))
+		})},
+	}
+}
+
+// trackKeys are the keys for changing the playlist, when the track pane has
+// focus
+func (s *SpotScreenPlaylists) trackKeys() []keybinding {
+	editing := func(edit func() error) func(g *Spot) {
+		return func(g *Spot) {
+			if s.tracksSL.Len() == 0 || s.tracksSL.playlist == nil {
+				return
+			}
+			if err := edit(); err != nil {
+				g.fail(err.Error())
+			}
+		}
+	}
+	move := func(by int) func() error {
+		return func() error {
+			if !s.tracksSL.HasSelection() {
+				return nil
+			}
+			if s.tracksSL.Sorted() {
+				return errors.New("Sort by # or turn sorting off to reorder tracks")
+			}
+			selected := s.tracksSL.SelectedIndex()
+			return s.MoveTrack(s.tracksSL.playlist, selected, selected+by)
+		}
+	}
+	return []keybinding{
+		{keys: []tb.Key{tb.KeyEnter}, name: "enter", help: "Play the track, and queue the rest of the playlist", run: func(*Spot) {
+			s.playSelected()
+		}},
+		{ch: 'd', name: "d", help: "Remove the marked or selected tracks from the playlist", run: editing(func() error {
+			targets := s.tracksSL.Targets()
+			if len(targets) == 0 {
+				return nil
+			}
+			defer s.tracksSL.ClearMarks()
+			return s.RemoveTracks(s.tracksSL.playlist, targets)
+		})},
+		{ch: 'K', name: "K", help: "Move the track up, down", run: editing(move(-1))},
+		{ch: 'J', name: "J", help: "Move the track up, down", run: editing(move(1))},
+	}
+}
+
+// Keys lists the keys for whichever pane has focus
+func (s *SpotScreenPlaylists) Keys() []ui.KeyHelp {
+	keys := keyHelp(s.keys())
+	if s.tracksfocussed {
+		return append(keys, s.tracksSL.Keys()...)
+	}
+	return append(keys, ui.ListKeys...)
 }
 
 func (s *SpotScreenPlaylists) HandleTBEvent(ev tb.Event) {
-	switch ev.Key {
-	case tb.KeyTab:
-		// Swap focus between playlist and track scrolllists
-		s.tracksfocussed = !s.tracksfocussed
-	case tb.KeyArrowUp:
-		if s.tracksfocussed {
-			s.tracksSL.SelectUp()
-		} else {
-			s.playlistsSL.SelectUp()
-			s.playlistchanged = true
-		}
-	case tb.KeyArrowDown:
-		if s.tracksfocussed {
-			s.tracksSL.SelectDown()
-		} else {
-			s.playlistsSL.SelectDown()
-			s.playlistchanged = true
-		}
-	case tb.KeyEnter:
-		if s.tracksfocussed {
-			playlistcont, err := spot.session.Playlists()
-			if err != nil {
-				spot.cmdline.status = err.Error()
-			} else {
-				playlistcont.Wait()
-				if playlistcont.PlaylistType(s.playlistsSL.Items[s.playlistsSL.Selected].Data) == sp.PlaylistTypePlaylist {
-					playlist := playlistcont.Playlist(s.playlistsSL.Items[s.playlistsSL.Selected].Data)
-					playlist.Wait()
-					if s.tracksSL.sl.Selected < playlist.Tracks() {
-						track := playlist.Track(s.tracksSL.sl.Selected).Track()
-						track.Wait()
-						spot.Player.Load(track)
-						spot.Player.PlayPause()
-						s.tracksSL.sl.Highlit = s.tracksSL.sl.Selected
-						s.playlistsSL.Highlit = s.playlistsSL.Selected
-					}
+	if ev.Type == tb.EventMouse {
+		s.handleMouse(ev)
+		return
+	}
+	if s.tracksfocussed {
+		if s.tracksSL.HandleKey(ev) {
+			return
+		}
+	} else {
+		selected := s.playlistsSL.Selected
+		if s.playlistsSL.HandleKey(ev) {
+			s.playlistchanged = s.playlistchanged || s.playlistsSL.Selected != selected
+			return
+		}
+	}
+	spot.runKey(s.keys(), ev)
+}
+
+// handleMouse handles clicks and the wheel over either pane. Clicking a pane,
+// or the dividing line, moves the focus; double clicking a track plays it.
+func (s *SpotScreenPlaylists) handleMouse(ev tb.Event) {
+	for _, d := range s.split.DividerRects(s.panes) {
+		if ev.Key == tb.MouseLeft && d.Contains(ev.MouseX, ev.MouseY) {
+			s.tracksfocussed = !s.tracksfocussed
+			return
+		}
+	}
+	selected := s.playlistsSL.Selected
+	if !s.split.Panes[playlistsPane].Hidden {
+		switch s.playlistsSL.HandleMouse(ev) {
+		case ui.MouseSelected:
+			s.tracksfocussed = false
+		case ui.MouseActivated:
+			s.tracksfocussed = false
+			s.ToggleFolder()
+		}
+	}
+	s.playlistchanged = s.playlistchanged || s.playlistsSL.Selected != selected
+	switch s.tracksSL.sl.HandleMouse(ev) {
+	case ui.MouseSelected:
+		s.tracksfocussed = true
+	case ui.MouseActivated:
+		s.tracksfocussed = true
+		s.playSelected()
+	}
+}
+
+// playSelected plays from the selected track on, in the order shown
+func (s *SpotScreenPlaylists) playSelected() {
+	if !s.tracksSL.HasSelection() {
+		return
+	}
+	if err := s.tracksSL.PlaySelected(); err != nil {
+		spot.fail(err.Error())
+	}
+	s.playlistsSL.Highlit = s.playlistsSL.Selected
+}
+
+// FocussedTracks returns the track list if it has focus
+func (s *SpotScreenPlaylists) FocussedTracks() *TrackList {
+	if s.tracksfocussed {
+		return s.tracksSL
+	}
+	return nil
+}
+
+// askPlaylistName asks for a name, starting with name typed in, and runs
+// :playlist action with it
+func askPlaylistName(title, action, name string) {
+	spot.OpenDialog(ui.NewInput(title, "Name:", name, func(name string, ok bool) {
+		if ok && strings.TrimSpace(name) != "" {
+			spot.status(spot.docommand("playlist", []string{action, name}))
+		}
+	}))
+}
+
+// NewPlaylist creates an empty playlist at the end of the container
+func (s *SpotScreenPlaylists) NewPlaylist(name string) string {
+	if _, err := s.playlists.AddNewPlaylist(name); err != nil {
+		return spot.fail(err.Error())
+	}
+	s.selectIndex(s.playlists.Playlists() - 1)
+	return "Created playlist " + name
+}
+
+// NewFolder creates an empty folder just above the selected item
+func (s *SpotScreenPlaylists) NewFolder(name string) string {
+	index := 0
+	if s.selectedInContainer() {
+		index = s.selectedIndex()
+	}
+	if err := s.playlists.AddFolder(index, name); err != nil {
+		return spot.fail(err.Error())
+	}
+	s.selectIndex(index)
+	return "Created folder " + name
+}
+
+// RenameSelected renames the selected playlist. Folders can't be renamed.
+func (s *SpotScreenPlaylists) RenameSelected(name string) string {
+	if !s.selectedInContainer() || s.playlists.PlaylistType(s.selectedIndex()) != sp.PlaylistTypePlaylist {
+		return "Select one of your playlists to rename"
+	}
+	if err := s.playlists.Playlist(s.selectedIndex()).Rename(name); err != nil {
+		return spot.fail(err.Error())
+	}
+	return ""
+}
+
+// SelectedName returns the name of the selected playlist or folder
+func (s *SpotScreenPlaylists) SelectedName() string {
+	index := s.selectedIndex()
+	if index < 0 {
+		return s.playlistsSL.Items[s.playlistsSL.Selected].TextL
+	}
+	if s.playlists.PlaylistType(index) == sp.PlaylistTypeStartFolder {
+		folder, _ := s.playlists.Folder(index)
+		return folder.Name()
+	}
+	return s.playlists.Playlist(index).Name()
+}
+
+// DeleteSelected removes the selected playlist or folder from the container.
+// Deleting a folder leaves its contents in place.
+func (s *SpotScreenPlaylists) DeleteSelected() string {
+	if !s.selectedInContainer() {
+		return "Select one of your playlists to delete"
+	}
+	name := s.SelectedName()
+	if err := s.playlists.RemovePlaylist(s.selectedIndex()); err != nil {
+		return spot.fail(err.Error())
+	}
+	s.playlistchanged = true
+	return "Deleted " + name
+}
+
+// MoveSelected moves the selected playlist or folder delta places through the
+// container, which takes it into or out of any folders it passes
+func (s *SpotScreenPlaylists) MoveSelected(delta int) string {
+	if !s.selectedInContainer() {
+		return ""
+	}
+	from := s.selectedIndex()
+	to := from + delta
+	if to < 0 || to >= s.playlists.Playlists() {
+		return ""
+	}
+	if err := s.playlists.MovePlaylist(from, reorderPosition(from, to)); err != nil {
+		return spot.fail(err.Error())
+	}
+	s.selectIndex(to)
+	return ""
+}
+
+// ToggleFolder collapses the selected folder, or expands it if collapsed
+func (s *SpotScreenPlaylists) ToggleFolder() {
+	if !s.selectedInContainer() || s.playlists.PlaylistType(s.selectedIndex()) != sp.PlaylistTypeStartFolder {
+		return
+	}
+	folder, err := s.playlists.Folder(s.selectedIndex())
+	if err != nil {
+		return
+	}
+	s.collapsed[folder.Id()] = !s.collapsed[folder.Id()]
+}
+
+// FindPlaylist returns the playlist called name, ignoring case. A prefix is
+// enough if it only matches one playlist.
+func (s *SpotScreenPlaylists) FindPlaylist(name string) (*sp.Playlist, error) {
+	if s.playlists == nil {
+		return nil, errors.New("Playlists not loaded")
+	}
+	var matches []*sp.Playlist
+	for i := 0; i < s.playlists.Playlists(); i++ {
+		if s.playlists.PlaylistType(i) != sp.PlaylistTypePlaylist {
+			continue
+		}
+		playlist := s.playlists.Playlist(i)
+		playlist.Wait()
+		switch {
+		case strings.EqualFold(playlist.Name(), name):
+			return playlist, nil
+		case strings.HasPrefix(strings.ToLower(playlist.Name()), strings.ToLower(name)):
+			matches = append(matches, playlist)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("No playlist called %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("%q matches %d playlists", name, len(matches))
+}
+
+// AddTracks appends tracks to the end of playlist
+func (s *SpotScreenPlaylists) AddTracks(playlist *sp.Playlist, tracks []*sp.Track) error {
+	pos := playlist.Tracks()
+	for i, track := range tracks {
+		if err := s.insertTrack(playlist, pos+i, track); err != nil {
+			return err
+		}
+	}
+	s.edits = append(s.edits, playlistEdit{
+		desc: fmt.Sprintf("Added %s to %s", describeTracks(tracks), playlist.Name()),
+		undo: func() error {
+			for i := len(tracks) - 1; i >= 0; i-- {
+				if err := s.removeTrack(playlist, pos+i); err != nil {
+					return err
 				}
 			}
+			return nil
+		},
+	})
+	return nil
+}
+
+// RemoveTracks removes the tracks at indexes from playlist
+func (s *SpotScreenPlaylists) RemoveTracks(playlist *sp.Playlist, indexes []int) error {
+	indexes = append([]int(nil), indexes...)
+	// Remove from the end first, so the other indexes stay put
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	var removed []*sp.Track
+	var err error
+	for n, i := range indexes {
+		track := playlist.Track(i).Track()
+		if err = s.removeTrack(playlist, i); err != nil {
+			indexes = indexes[:n]
+			break
 		}
+		removed = append(removed, track)
+	}
+	if len(removed) == 0 {
+		return err
+	}
+	s.edits = append(s.edits, playlistEdit{
+		desc: fmt.Sprintf("Removed %s from %s", describeTracks(removed), playlist.Name()),
+		undo: func() error {
+			for n := len(indexes) - 1; n >= 0; n-- {
+				if err := s.insertTrack(playlist, indexes[n], removed[n]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	return err
+}
+
+// describeTracks names the track, if there's only one, for status messages
+func describeTracks(tracks []*sp.Track) string {
+	if len(tracks) == 1 {
+		return tracks[0].Name()
+	}
+	return fmt.Sprintf("%d tracks", len(tracks))
+}
+
+// MoveTrack moves track i of playlist to position j
+func (s *SpotScreenPlaylists) MoveTrack(playlist *sp.Playlist, i, j int) error {
+	if j < 0 || j >= playlist.Tracks() {
+		return nil
+	}
+	if err := s.moveTrack(playlist, i, j); err != nil {
+		return err
+	}
+	s.edits = append(s.edits, playlistEdit{
+		desc: fmt.Sprintf("Moved %s in %s", playlist.Track(j).Track().Name(), playlist.Name()),
+		undo: func() error { return s.moveTrack(playlist, j, i) },
+	})
+	return nil
+}
+
+// Undo reverts the most recent playlist edit, returning a status message
+func (s *SpotScreenPlaylists) Undo() string {
+	if len(s.edits) == 0 {
+		return "Nothing to undo"
+	}
+	edit := s.edits[len(s.edits)-1]
+	if err := edit.undo(); err != nil {
+		return spot.fail(err.Error())
+	}
+	s.edits = s.edits[:len(s.edits)-1]
+	return "Undid: " + edit.desc
+}
+
+// samePlaylist returns whether a and b are the same playlist. Like tracks,
+// playlists get a new *sp.Playlist each time they're fetched, e.g. by
+// FindPlaylist or on being selected again, so they're compared by link.
+func samePlaylist(a, b *sp.Playlist) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a == b || a.Link().String() == b.Link().String()
+}
+
+// Changes to playlists go through these, so the track list stays in step when
+// the playlist being changed is the one on screen
+
+func (s *SpotScreenPlaylists) insertTrack(playlist *sp.Playlist, i int, track *sp.Track) error {
+	if samePlaylist(playlist, s.tracksSL.playlist) {
+		return s.tracksSL.Insert(i, track)
+	}
+	return playlist.AddTracks(i, track)
+}
+
+func (s *SpotScreenPlaylists) removeTrack(playlist *sp.Playlist, i int) error {
+	if samePlaylist(playlist, s.tracksSL.playlist) {
+		return s.tracksSL.Remove(i)
 	}
+	return playlist.RemoveTracks(i)
+}
+
+func (s *SpotScreenPlaylists) moveTrack(playlist *sp.Playlist, i, j int) error {
+	if samePlaylist(playlist, s.tracksSL.playlist) {
+		return s.tracksSL.Move(i, j)
+	}
+	return playlist.ReorderTracks([]int{i}, reorderPosition(i, j))
+}
+
+// reorderPosition converts a move of a track from i to j into the position
+// libspotify wants, which is counted before the track is taken out
+func reorderPosition(i, j int) int {
+	if j > i {
+		return j + 1
+	}
+	return j
 }
 
 func (s *SpotScreenPlaylists) SetPlaylists(playlists *sp.PlaylistContainer) {
 	s.playlists = playlists
 }
 
-type TrackList struct {
-	sl     ui.ScrollList
-	tracks []*sp.Track
+// SpotScreenEQ shows the equaliser as a row of sliders, one per band followed
+// by the bass and treble shelves
+type SpotScreenEQ struct {
+	dsp      *DSPChain
+	selected int // Index into EQBands, or len(EQBands) for bass and +1 for treble
 }
 
-func NewTrackList() *TrackList {
-	return &TrackList{sl: ui.NewScrollList()}
+func NewSpotScreenEQ(dsp *DSPChain) SpotScreenEQ {
+	return SpotScreenEQ{dsp: dsp}
 }
 
-func (t *TrackList) AddTrack(track *sp.Track) {
-	disabled := false
-	if track.Availability() != sp.TrackAvailabilityAvailable { // Track not playable
-		disabled = true
+// Labels for the sliders beneath the EQ bands
+var eqSliderLabels = []string{"31", "62", "125", "250", "500", "1k", "2k", "4k", "8k", "16k", "Bass", "Treb"}
+
+func (s *SpotScreenEQ) Draw(x, y, w, h int) {
+	if w < 20 || h < 10 {
+		ui.DrawTooSmall(ui.Rect{X: x, Y: y, W: w, H: h})
+		return
+	}
+	settings := s.dsp.Settings()
+	state := "off"
+	if settings.Enabled {
+		state = "on"
+	}
+	header := fmt.Sprintf("Equaliser %s  Preset: %s  Balance: %+.1f  Mono: %t", state, settings.Preset, settings.Balance, settings.Mono)
+	normal, dim, accent := ui.StyleOf(ui.RoleNormal), ui.StyleOf(ui.RoleDim), ui.StyleOf(ui.RoleAccent)
+	ui.Print(x+1, y+1, normal.Fg, normal.Bg, header)
+	ui.Print(x+1, y+h-1, dim.Fg, dim.Bg, "h/l: select  j/k: adjust  p: next preset  e: on/off  m: mono  </>: balance")
+
+	gains := append(settings.Bands, settings.Bass, settings.Treble)
+	sliderh := h - 7
+	if sliderh > 25 {
+		sliderh = 25
+	}
+	for i, gain := range gains {
+		col := x + 3 + i*6
+		if col+4 > x+w {
+			break
+		}
+		style := normal
+		if i == s.selected {
+			style = accent
+		}
+		ui.Drawslider(col, y+3, sliderh, (gain+eqMaxGain)/(2*eqMaxGain), style.Fg)
+		ui.Printc(col, y+3+sliderh, style.Fg, style.Bg, eqSliderLabels[i])
+		ui.Printc(col, y+4+sliderh, style.Fg, style.Bg, fmt.Sprintf("%+.0f", gain))
 	}
-	t.sl.Items = append(t.sl.Items, ui.ListItem{track.Name(), track.Artist(0).Name(), 0, disabled})
-	t.tracks = append(t.tracks, track)
 }
 
-// SetPlaylist clears the tracklist and populates it with the contents of playlist
-func (t *TrackList) SetPlaylist(playlist *sp.Playlist) {
-	t.Clear()
-	for i := 0; i < playlist.Tracks(); i++ {
-		track := playlist.Track(i).Track()
-		track.Wait()
-		t.AddTrack(track)
+// keys are the EQ screen's keys. The sliders are only selected, but
+// everything else changes the settings, which are saved.
+func (s *SpotScreenEQ) keys() []keybinding {
+	sliders := len(EQBands) + 2
+	return []keybinding{
+		{ch: 'h', name: "h", help: "Select the previous, next slider", run: func(*Spot) {
+			s.selected = (s.selected + sliders - 1) % sliders
+		}},
+		{keys: []tb.Key{tb.KeyTab}, ch: 'l', name: "l tab", help: "Select the previous, next slider", run: func(*Spot) {
+			s.selected = (s.selected + 1) % sliders
+		}},
+		{keys: []tb.Key{tb.KeyArrowDown}, ch: 'j', name: "j ↓", help: "Turn the slider down, up", run: func(*Spot) {
+			s.adjust(-1)
+		}},
+		{keys: []tb.Key{tb.KeyArrowUp}, ch: 'k', name: "k ↑", help: "Turn the slider down, up", run: func(*Spot) {
+			s.adjust(1)
+		}},
+		{ch: 'p', name: "p", help: "Switch to the next preset", run: func(*Spot) {
+			// Cycle to the next preset, from custom to the first
+			names := EQPresetNames()
+			current := s.dsp.Settings().Preset
+			next := names[0]
+			for i, name := range names {
+				if name == current {
+					next = names[(i+1)%len(names)]
+				}
+			}
+			s.dsp.SetPreset(next)
+		}},
+		{ch: 'e', name: "e", help: "Turn the equaliser on or off", run: func(*Spot) {
+			s.dsp.SetEnabled(!s.dsp.Settings().Enabled)
+		}},
+		{ch: 'm', name: "m", help: "Turn mono on or off", run: func(*Spot) {
+			s.dsp.SetMono(!s.dsp.Settings().Mono)
+		}},
+		{ch: '<', name: "<", help: "Balance to the left, right", run: func(*Spot) {
+			s.dsp.SetBalance(s.dsp.Settings().Balance - 0.1)
+		}},
+		{ch: '>', name: ">", help: "Balance to the left, right", run: func(*Spot) {
+			s.dsp.SetBalance(s.dsp.Settings().Balance + 0.1)
+		}},
+	}
+}
+
+func (s *SpotScreenEQ) Keys() []ui.KeyHelp {
+	return keyHelp(s.keys())
+}
+
+func (s *SpotScreenEQ) HandleTBEvent(ev tb.Event) {
+	selected := s.selected
+	if !spot.runKey(s.keys(), ev) || s.selected != selected {
+		return
+	}
+	if msg := spot.saveEQ(); msg != "" {
+		spot.status(msg)
+	}
+}
+
+func (s *SpotScreenEQ) adjust(delta float64) {
+	switch {
+	case s.selected < len(EQBands):
+		s.dsp.AdjustBand(s.selected, delta)
+	case s.selected == len(EQBands):
+		s.dsp.AdjustShelf(false, delta)
+	default:
+		s.dsp.AdjustShelf(true, delta)
+	}
+}
+
+const (
+	visFFTSize = 2048    // Samples per spectrum, must be a power of two
+	visMinFreq = 40.0    // Frequency of the leftmost spectrum bar
+	visMaxFreq = 16000.0 // Frequency of the rightmost spectrum bar
+	visFloor   = -60.0   // Level in dB drawn as an empty bar
+	visFalloff = 0.85    // How much of its height a bar keeps each frame
+)
+
+// SpotScreenVisualiser draws a spectrum analyser and VU meters of whatever is
+// playing, from the AudioWriter's tap
+type SpotScreenVisualiser struct {
+	tap     *AudioTap
+	samples []float64
+	bars    []float64 // Smoothed height of each spectrum bar, from 0 to 1
+	levels  []float64 // Smoothed RMS level of each channel, from 0 to 1
+}
+
+func NewSpotScreenVisualiser(tap *AudioTap) SpotScreenVisualiser {
+	return SpotScreenVisualiser{tap: tap, samples: make([]float64, visFFTSize)}
+}
+
+// dbFrac maps an amplitude from 0 to 1 onto the visualiser's dB scale
+func dbFrac(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return 0
+	}
+	db := 20 * math.Log10(amplitude)
+	return math.Max(0, math.Min(1, (db-visFloor)/-visFloor))
+}
+
+// levelColour picks a meter colour from how close to full scale it is
+func levelColour(frac float64) tb.Attribute {
+	switch {
+	case frac > 0.9:
+		return ui.StyleOf(ui.RoleError).Fg
+	case frac > 0.7:
+		return ui.StyleOf(ui.RoleWarning).Fg
 	}
+	return ui.StyleOf(ui.RoleGood).Fg
 }
 
-func (t *TrackList) Clear() {
-	t.sl.Clear()
-	t.tracks = nil
+func (s *SpotScreenVisualiser) Draw(x, y, w, h int) {
+	meterh := 3 // A meter for each of two channels, and a blank row above
+	spech := h - meterh
+	if w < 4 || spech < 1 {
+		ui.DrawTooSmall(ui.Rect{X: x, Y: y, W: w, H: h})
+		return
+	}
+	if len(s.bars) != w {
+		s.bars = make([]float64, w)
+	}
+
+	rate, ok := s.tap.Samples(s.samples)
+	var mags []float64
+	if ok {
+		mags = spectrum(s.samples)
+	}
+	maxfreq := math.Min(visMaxFreq, float64(rate)/2)
+	for i := range s.bars {
+		var level float64
+		if ok {
+			// Bars are spaced logarithmically, each taking the loudest bin
+			// in its range of frequencies
+			lo := visMinFreq * math.Pow(maxfreq/visMinFreq, float64(i)/float64(w))
+			hi := visMinFreq * math.Pow(maxfreq/visMinFreq, float64(i+1)/float64(w))
+			binlo := int(lo * visFFTSize / float64(rate))
+			binhi := int(hi * visFFTSize / float64(rate))
+			var mag float64
+			for bin := binlo; bin <= binhi && bin < len(mags); bin++ {
+				mag = math.Max(mag, mags[bin])
+			}
+			level = dbFrac(mag)
+		}
+		s.bars[i] = math.Max(level, s.bars[i]*visFalloff)
+		ui.Drawvmeter(x+i, y+spech-1, spech, s.bars[i], levelColour(s.bars[i]))
+	}
+
+	rms, peak := s.tap.Levels()
+	if len(rms) != len(s.levels) && rms != nil {
+		s.levels = make([]float64, len(rms))
+	}
+	normal := ui.StyleOf(ui.RoleNormal)
+	labels := []string{"L", "R"}
+	if len(s.levels) == 1 {
+		labels = []string{"M"}
+	}
+	for ch := range s.levels {
+		if ch >= len(labels) {
+			break
+		}
+		var level float64
+		if rms != nil {
+			level = dbFrac(rms[ch])
+		}
+		s.levels[ch] = math.Max(level, s.levels[ch]*visFalloff)
+		row := y + spech + 1 + ch
+		ui.Print(x, row, normal.Fg, normal.Bg, labels[ch])
+		ui.Drawhmeter(x+2, row, w-2, s.levels[ch], levelColour(s.levels[ch]))
+		if peak != nil {
+			// Mark the peak level with a tick
+			peakx := int(dbFrac(peak[ch]) * float64(w-3))
+			tb.SetCell(x+2+peakx, row, '|', normal.Fg, normal.Bg)
+		}
+	}
 }
 
-func (t *TrackList) Draw(x, y, w, h int, focussed bool) {
-	t.sl.Draw(x, y, w, h, focussed)
+func (SpotScreenVisualiser) HandleTBEvent(tb.Event) {
 }
 
-func (t *TrackList) SelectUp() {
-	t.sl.SelectUp()
+// SpotScreenDiagnostics shows the state of the audio pipeline, for working out
+// what went wrong when playback misbehaves
+type SpotScreenDiagnostics struct {
+	aw *AudioWriter
 }
 
-func (t *TrackList) SelectDown() {
-	t.sl.SelectDown()
+func (s *SpotScreenDiagnostics) Draw(x, y, w, h int) {
+	device := s.aw.DeviceStats()
+	buffer := s.aw.Stats()
+	state := "OK"
+	if device.Failing {
+		state = "Failing"
+	}
+	lasterr := "None"
+	if device.LastErr != nil {
+		lasterr = device.LastErr.Error()
+	}
+	lines := []string{
+		"Audio device:   " + device.Device,
+		"Device state:   " + state,
+		fmt.Sprintf("Device errors:  %d", device.Errors),
+		"Last error:     " + lasterr,
+		"",
+		fmt.Sprintf("Buffered:       %s of %s", buffer.Buffered, buffer.Capacity),
+		fmt.Sprintf("Rejected:       %d", buffer.Rejected),
+	}
+	normal, errstyle := ui.StyleOf(ui.RoleNormal), ui.StyleOf(ui.RoleError)
+	for i, line := range lines {
+		ui.Printlim(x+1, y+1+i, normal.Fg, normal.Bg, line, w-2)
+	}
+	ui.Drawhmeter(x+17, y+len(lines)+1, w-19, buffer.Fill(), ui.StyleOf(ui.RoleMeter).Fg)
+	if device.Failing {
+		ui.Print(x+1, y+len(lines)+3, errstyle.Fg, errstyle.Bg, "Playback is paused until the device comes back")
+	}
 }
 
-func (t *TrackList) GetSelected() *sp.Track {
-	return t.tracks[t.sl.Selected]
+func (SpotScreenDiagnostics) HandleTBEvent(tb.Event) {
 }
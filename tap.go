@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	sp "github.com/op/go-libspotify/spotify"
+)
+
+// How long after the last audio was written the tap reports silence, so
+// visualisations fall away when playback pauses or stops
+var tapStaleAfter = 200 * time.Millisecond
+
+// AudioTap keeps a copy of the most recent audio written to the device, for
+// visualisation. The device writer never waits for it: if a reader holds the
+// tap when audio arrives, that audio is simply not recorded.
+type AudioTap struct {
+	mu      sync.Mutex
+	samples []float64 // Ring of the most recent mono samples, from -1 to 1
+	pos     int       // Where the next sample goes in samples
+	rate    int
+	rms     []float64 // Level of each channel over the last write
+	peak    []float64
+	updated time.Time
+}
+
+// NewAudioTap returns a tap which remembers the last size samples
+func NewAudioTap(size int) *AudioTap {
+	return &AudioTap{samples: make([]float64, size)}
+}
+
+// write records frames, which are about to be played. It must not block.
+func (t *AudioTap) write(frames []byte, format sp.AudioFormat) {
+	if format.Channels == 0 || !t.mu.TryLock() {
+		return
+	}
+	defer t.mu.Unlock()
+	channels := format.Channels
+	if len(t.rms) != channels {
+		t.rms = make([]float64, channels)
+		t.peak = make([]float64, channels)
+	}
+	for ch := range t.rms {
+		t.rms[ch], t.peak[ch] = 0, 0
+	}
+	n := 0
+	for i := 0; i+channels*2 <= len(frames); i += channels * 2 {
+		var mono float64
+		for ch := 0; ch < channels; ch++ {
+			s := float64(int16(binary.NativeEndian.Uint16(frames[i+ch*2:]))) / 32768
+			mono += s
+			t.rms[ch] += s * s
+			t.peak[ch] = math.Max(t.peak[ch], math.Abs(s))
+		}
+		t.samples[t.pos] = mono / float64(channels)
+		t.pos = (t.pos + 1) % len(t.samples)
+		n++
+	}
+	if n > 0 {
+		for ch := range t.rms {
+			t.rms[ch] = math.Sqrt(t.rms[ch] / float64(n))
+		}
+	}
+	t.rate = format.SampleRate
+	t.updated = time.Now()
+}
+
+func (t *AudioTap) stale() bool {
+	return time.Since(t.updated) > tapStaleAfter
+}
+
+// Samples fills dst with the most recent mono samples, oldest first, and
+// returns their sample rate. It returns false if nothing has been played
+// recently, leaving dst untouched.
+func (t *AudioTap) Samples(dst []float64) (rate int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stale() || t.rate == 0 {
+		return 0, false
+	}
+	start := t.pos - len(dst)
+	for start < 0 {
+		start += len(t.samples)
+	}
+	for i := range dst {
+		dst[i] = t.samples[(start+i)%len(t.samples)]
+	}
+	return t.rate, true
+}
+
+// Levels returns the RMS and peak level, from 0 to 1, of each channel of the
+// most recently played audio. Both are nil if nothing is playing.
+func (t *AudioTap) Levels() (rms, peak []float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stale() {
+		return nil, nil
+	}
+	return append([]float64(nil), t.rms...), append([]float64(nil), t.peak...)
+}
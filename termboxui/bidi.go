@@ -0,0 +1,173 @@
+package termboxui
+
+import (
+	"os"
+	"unicode"
+)
+
+// Termbox puts each cell where it's told, left to right, so right-to-left
+// text such as Hebrew and Arabic would come out backwards. Print reorders it
+// into the order it's read in, much as the Unicode bidi algorithm does for a
+// single line: runs of right-to-left text are reversed, numbers within them
+// still read left to right, and brackets are mirrored. Each call to Print is
+// reordered on its own, so right-to-left text stays within its table cell or
+// aligned position.
+//
+// Some terminals reorder lines themselves, which would undo this, so it's
+// off for those. They reorder whole lines, not cells, so alignment there is
+// up to the terminal.
+
+// ReorderRTL is whether Print reorders right-to-left text
+var ReorderRTL = !terminalReordersRTL()
+
+// terminalReordersRTL returns whether the terminal looks like one which
+// applies the bidi algorithm to its lines
+func terminalReordersRTL() bool {
+	return os.Getenv("KONSOLE_VERSION") != "" || os.Getenv("MLTERM") != ""
+}
+
+// Directions of grapheme clusters, after their first rune
+const (
+	dirNeutral = iota // Spaces and punctuation, which take their neighbours' direction
+	dirLTR
+	dirRTL
+	dirNumber // Read left to right, even in right-to-left text
+)
+
+// rtlScripts are the scripts written right to left
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko,
+}
+
+func direction(r rune) int {
+	switch {
+	case unicode.IsDigit(r):
+		return dirNumber
+	case unicode.In(r, rtlScripts...):
+		return dirRTL
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return dirLTR
+	}
+	return dirNeutral
+}
+
+// bidiMirrors are the characters which are swapped for each other in
+// right-to-left text, so they still face the right way
+var bidiMirrors = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+// cluster is a grapheme cluster of a string, with its first rune's index in
+// the string counted in runes
+type cluster struct {
+	runes []rune
+	width int
+	index int
+}
+
+// visualOrder returns clusters, which are in the order they're read, in the
+// order they should be drawn from left to right
+func visualOrder(clusters []cluster) []cluster {
+	dirs := make([]int, len(clusters))
+	rtl := false
+	for i, c := range clusters {
+		dirs[i] = direction(c.runes[0])
+		rtl = rtl || dirs[i] == dirRTL
+	}
+	if !rtl || !ReorderRTL {
+		return clusters
+	}
+
+	// The first strong direction is the direction of the whole line
+	base := dirLTR
+	for _, d := range dirs {
+		if d == dirLTR || d == dirRTL {
+			base = d
+			break
+		}
+	}
+	// Numbers take the direction of the text before them for placing
+	// neutrals, and neutrals that of the text either side, if it agrees,
+	// or otherwise the line's
+	resolved := make([]int, len(dirs))
+	prev := base
+	for i, d := range dirs {
+		switch d {
+		case dirLTR, dirRTL:
+			prev = d
+			resolved[i] = d
+		case dirNumber:
+			resolved[i] = prev
+		}
+	}
+	for i := 0; i < len(dirs); {
+		if dirs[i] != dirNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(dirs) && dirs[j] == dirNeutral {
+			j++
+		}
+		before, after := base, base
+		if i > 0 {
+			before = resolved[i-1]
+		}
+		if j < len(dirs) {
+			after = resolved[j]
+		}
+		d := base
+		if before == after {
+			d = before
+		}
+		for ; i < j; i++ {
+			resolved[i] = d
+		}
+	}
+
+	// Embedding levels: left to right text is even and right to left odd.
+	// Numbers in right to left text go up a level, to read left to right.
+	levels := make([]int, len(dirs))
+	maxlevel := 0
+	for i, d := range resolved {
+		switch {
+		case dirs[i] == dirNumber && (d == dirRTL || base == dirRTL):
+			levels[i] = 2
+		case d == dirRTL:
+			levels[i] = 1
+		case base == dirRTL:
+			levels[i] = 2
+		}
+		if levels[i] > maxlevel {
+			maxlevel = levels[i]
+		}
+	}
+
+	// From the highest level down, reverse each run at that level or above
+	visual := append([]cluster(nil), clusters...)
+	for level := maxlevel; level > 0; level-- {
+		for i := 0; i < len(visual); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(visual) && levels[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				visual[a], visual[b] = visual[b], visual[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = j
+		}
+	}
+	for i := range visual {
+		if m, ok := bidiMirrors[visual[i].runes[0]]; ok && levels[i]%2 == 1 {
+			runes := append([]rune{m}, visual[i].runes[1:]...)
+			visual[i].runes = runes
+		}
+	}
+	return visual
+}
@@ -0,0 +1,266 @@
+package termboxui
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// Dialog is a modal box drawn over the top of the screen, which takes every
+// key until it closes. Dialogs report what the user chose through a callback,
+// run from HandleKey, so it runs wherever keys are handled.
+type Dialog interface {
+	// Draw draws the dialog centred in r
+	Draw(r Rect)
+	// HandleKey handles a key, returning true once the dialog has closed
+	HandleKey(ev termbox.Event) bool
+}
+
+// Widest a dialog gets, so text doesn't stretch right across wide terminals
+const dialogMaxWidth = 60
+
+// drawDialog clears a box w cells wide with room for h rows inside it,
+// centred in r and shrunk to fit if need be, and draws its border and title.
+// It returns the area inside the border, with a cell of space either side.
+func drawDialog(r Rect, w, h int, title string) Rect {
+	w, h = w+4, h+2
+	if w > r.W {
+		w = r.W
+	}
+	if h > r.H {
+		h = r.H
+	}
+	box := Rect{r.X + (r.W-w)/2, r.Y + (r.H-h)/2, w, h}
+	for y := box.Y; y < box.Y+box.H; y++ {
+		Fill(box.X, y, box.W, StyleOf(RoleNormal))
+	}
+	Drawbox(box.X, box.Y, box.W, box.H, title)
+	return Rect{box.X + 2, box.Y + 1, box.W - 4, box.H - 2}
+}
+
+// dialogWidth returns the width of the inside of a dialog drawn in r, enough
+// for want if there's room
+func dialogWidth(r Rect, want int) int {
+	w := r.W - 4
+	if w > dialogMaxWidth {
+		w = dialogMaxWidth
+	}
+	if want < w {
+		w = want
+	}
+	return w
+}
+
+// drawButtons draws buttons centred on row y of r, picking out the selected one
+func drawButtons(r Rect, y int, buttons []string, selected int) {
+	w := 0
+	for _, b := range buttons {
+		w += StringWidth(b) + 6
+	}
+	x := r.X + (r.W-w)/2
+	for i, b := range buttons {
+		style := StyleOf(RoleNormal)
+		if i == selected {
+			style = StyleOf(RoleSelectedFocus)
+		}
+		label := "[ " + b + " ]"
+		Print(x, y, style.Fg, style.Bg, label)
+		x += StringWidth(label) + 2
+	}
+}
+
+// Confirm asks a yes or no question. y and n answer it, as does Enter with
+// whichever button is selected; Esc answers no.
+type Confirm struct {
+	Title    string
+	Question string
+	Selected int // 0 for yes, 1 for no
+	OnAnswer func(yes bool)
+}
+
+// NewConfirm returns a Confirm asking question, with yes selected
+func NewConfirm(title, question string, onanswer func(yes bool)) *Confirm {
+	return &Confirm{Title: title, Question: question, OnAnswer: onanswer}
+}
+
+func (c *Confirm) Draw(r Rect) {
+	w := dialogWidth(r, StringWidth(c.Question))
+	if w < 16 {
+		w = 16
+	}
+	lines := Wrap(c.Question, w)
+	in := drawDialog(r, w, len(lines)+2, c.Title)
+	normal := StyleOf(RoleNormal)
+	for i, line := range lines {
+		if i >= in.H-2 { // Squashed by a small terminal
+			break
+		}
+		Print(in.X, in.Y+i, normal.Fg, normal.Bg, line)
+	}
+	drawButtons(in, in.Y+in.H-1, []string{"Yes", "No"}, c.Selected)
+}
+
+func (c *Confirm) HandleKey(ev termbox.Event) bool {
+	switch {
+	case ev.Ch == 'y' || ev.Ch == 'Y':
+		c.OnAnswer(true)
+	case ev.Ch == 'n' || ev.Ch == 'N' || ev.Key == termbox.KeyEsc:
+		c.OnAnswer(false)
+	case ev.Key == termbox.KeyEnter:
+		c.OnAnswer(c.Selected == 0)
+	case ev.Key == termbox.KeyTab || ev.Key == termbox.KeyArrowLeft || ev.Key == termbox.KeyArrowRight ||
+		ev.Ch == 'h' || ev.Ch == 'l':
+		c.Selected = 1 - c.Selected
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// Input asks for a line of text. Enter accepts it and Esc cancels.
+type Input struct {
+	Title  string
+	Prompt string
+	Text   []rune
+	OnDone func(text string, ok bool)
+}
+
+// NewInput returns an Input asking for text, starting with text already typed
+func NewInput(title, prompt, text string, ondone func(text string, ok bool)) *Input {
+	return &Input{Title: title, Prompt: prompt, Text: []rune(text), OnDone: ondone}
+}
+
+func (in *Input) Draw(r Rect) {
+	w := dialogWidth(r, dialogMaxWidth)
+	lines := Wrap(in.Prompt, w)
+	inner := drawDialog(r, w, len(lines)+2, in.Title)
+	normal, field := StyleOf(RoleNormal), StyleOf(RoleSelected)
+	for i, line := range lines {
+		if i >= inner.H-2 {
+			break
+		}
+		Print(inner.X, inner.Y+i, normal.Fg, normal.Bg, line)
+	}
+	// Show the end of the text, where it's being typed, followed by a cursor
+	y := inner.Y + inner.H - 1
+	Fill(inner.X, y, inner.W, field)
+	runes := in.Text
+	for len(runes) > 0 && StringWidth(string(runes)) > inner.W-1 {
+		runes = runes[1:]
+	}
+	text := string(runes)
+	Print(inner.X, y, field.Fg, field.Bg, text)
+	cursor := StyleOf(RoleSelectedFocus)
+	termbox.SetCell(inner.X+StringWidth(text), y, ' ', cursor.Fg, cursor.Bg)
+}
+
+func (in *Input) HandleKey(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.KeyEnter:
+		in.OnDone(string(in.Text), true)
+		return true
+	case termbox.KeyEsc:
+		in.OnDone(string(in.Text), false)
+		return true
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if len(in.Text) > 0 {
+			in.Text = in.Text[:len(in.Text)-1]
+		}
+	case termbox.KeyCtrlU:
+		in.Text = nil
+	case termbox.KeySpace:
+		in.Text = append(in.Text, ' ')
+	default:
+		if ev.Ch != 0 {
+			in.Text = append(in.Text, ev.Ch)
+		}
+	}
+	return false
+}
+
+// Choice asks the user to pick one of a list of options, which they can move
+// through as in any ScrollList. Enter picks the selected option; Esc cancels,
+// and the callback is given -1.
+type Choice struct {
+	Title    string
+	sl       ScrollList
+	OnChoose func(index int)
+}
+
+// NewChoice returns a Choice between options, with the first selected
+func NewChoice(title string, options []string, onchoose func(index int)) *Choice {
+	c := &Choice{Title: title, sl: NewScrollList(), OnChoose: onchoose}
+	items := make([]ListItem, len(options))
+	for i, o := range options {
+		items[i] = ListItem{TextL: o}
+	}
+	c.sl.SetItems(items)
+	return c
+}
+
+func (c *Choice) Draw(r Rect) {
+	w := StringWidth(c.Title) + 2
+	for _, item := range c.sl.Items {
+		if iw := StringWidth(item.TextL); iw > w {
+			w = iw
+		}
+	}
+	in := drawDialog(r, dialogWidth(r, w), len(c.sl.Items), c.Title)
+	c.sl.Draw(in.X, in.Y, in.W, in.H, true)
+}
+
+func (c *Choice) HandleKey(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.KeyEnter:
+		if len(c.sl.Items) == 0 {
+			c.OnChoose(-1)
+		} else {
+			c.OnChoose(c.sl.Selected)
+		}
+		return true
+	case termbox.KeyEsc:
+		c.OnChoose(-1)
+		return true
+	}
+	c.sl.HandleKey(ev)
+	return false
+}
+
+// Message shows some text until Enter, Esc or Space is pressed
+type Message struct {
+	Title   string
+	Text    string
+	OnClose func() // Optional
+}
+
+// NewMessage returns a Message showing text
+func NewMessage(title, text string) *Message {
+	return &Message{Title: title, Text: text}
+}
+
+func (m *Message) Draw(r Rect) {
+	w := dialogWidth(r, StringWidth(m.Text))
+	if w < 8 {
+		w = 8
+	}
+	lines := Wrap(m.Text, w)
+	in := drawDialog(r, w, len(lines)+2, m.Title)
+	normal := StyleOf(RoleNormal)
+	for i, line := range lines {
+		if i >= in.H-2 { // Squashed by a small terminal
+			break
+		}
+		Print(in.X, in.Y+i, normal.Fg, normal.Bg, line)
+	}
+	drawButtons(in, in.Y+in.H-1, []string{"OK"}, 0)
+}
+
+func (m *Message) HandleKey(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.KeyEnter, termbox.KeyEsc, termbox.KeySpace:
+		if m.OnClose != nil {
+			m.OnClose()
+		}
+		return true
+	}
+	return false
+}
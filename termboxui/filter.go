@@ -0,0 +1,166 @@
+package termboxui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/nsf/termbox-go"
+)
+
+// SetFilter narrows the list down to the items matching query. Every space
+// separated word of the query must fuzzily match (i.e. its letters appear in
+// order, ignoring case) one of the item's TextL, TextR or Cells. An empty query
+// shows every item again. If the selected item is filtered out, the first
+// shown item is selected instead.
+func (l *ScrollList) SetFilter(query string) {
+	l.filter = query
+	if strings.TrimSpace(query) != "" {
+		l.search = query
+	}
+	l.offset = 0
+	l.refilter()
+	if l.view != nil && l.rowOf(l.Selected) < 0 && len(l.view) > 0 {
+		l.Selected = l.view[0]
+	}
+}
+
+// SelectionShown returns whether the selected item is in the list and shown.
+// When the filter hides it, nothing should act on it: there's no selection.
+func (l *ScrollList) SelectionShown() bool {
+	return l.Selected >= 0 && l.rowOf(l.Selected) >= 0
+}
+
+// Filter returns the current filter query
+func (l *ScrollList) Filter() string {
+	return l.filter
+}
+
+// Matched returns the rune positions that matched the filter in each field of
+// item index: TextL, then TextR, then each of the Cells. It returns nil when
+// the list isn't filtered.
+func (l *ScrollList) Matched(index int) [][]int {
+	return l.matches[index]
+}
+
+// refilter works out which items match the filter
+func (l *ScrollList) refilter() {
+	l.viewlen = len(l.Items)
+	words := strings.Fields(l.filter)
+	if len(words) == 0 {
+		l.view = nil
+		l.matches = nil
+		return
+	}
+	l.view = []int{}
+	l.matches = make(map[int][][]int)
+	for i, item := range l.Items {
+		fields := append([]string{item.TextL, item.TextR}, item.Cells...)
+		if matched, ok := matchFields(words, fields); ok {
+			l.view = append(l.view, i)
+			l.matches[i] = matched
+		}
+	}
+}
+
+// matchFields matches each word against fields, returning the positions
+// matched in each field
+func matchFields(words, fields []string) ([][]int, bool) {
+	matched := make([][]int, len(fields))
+	for _, word := range words {
+		found := false
+		for f, field := range fields {
+			if positions, ok := fuzzyMatch(word, field); ok {
+				matched[f] = append(matched[f], positions...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return matched, true
+}
+
+// fuzzyMatch reports whether the runes of query appear in order in text,
+// ignoring case, and returns the rune positions in text where they did
+func fuzzyMatch(query, text string) ([]int, bool) {
+	q := []rune(strings.ToLower(query))
+	var positions []int
+	pos := 0
+	for _, r := range text {
+		if len(positions) == len(q) {
+			break
+		}
+		if unicode.ToLower(r) == q[len(positions)] {
+			positions = append(positions, pos)
+		}
+		pos++
+	}
+	return positions, len(positions) == len(q)
+}
+
+// The following map between rows, which count only the items shown by the
+// filter, and indexes into Items
+
+func (l *ScrollList) stale() bool {
+	return l.filter != "" && l.viewlen != len(l.Items)
+}
+
+func (l *ScrollList) rowCount() int {
+	if l.stale() {
+		l.refilter()
+	}
+	if l.view == nil {
+		return len(l.Items)
+	}
+	return len(l.view)
+}
+
+func (l *ScrollList) itemAt(row int) int {
+	if l.view == nil {
+		return row
+	}
+	return l.view[row]
+}
+
+// rowOf returns the row item index is shown in, or -1 if it isn't shown
+func (l *ScrollList) rowOf(index int) int {
+	if l.stale() {
+		l.refilter()
+	}
+	if l.view == nil {
+		if index >= len(l.Items) {
+			return -1
+		}
+		return index
+	}
+	for row, i := range l.view {
+		if i == index {
+			return row
+		}
+	}
+	return -1
+}
+
+// highlightMatches redraws the characters of text at the given rune
+// positions, as printed at x, y by Printlim with a limit of lim, so they stand out
+func highlightMatches(x, y int, fg, bg termbox.Attribute, text string, lim int, positions []int) {
+	if len(positions) == 0 {
+		return
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+	eachVisualCluster(Truncate(text, lim), func(runes []rune, width, index int) bool {
+		for i := range runes {
+			if matched[index+i] && width > 0 {
+				setCell(x, y, runes[0], fg|termbox.AttrBold|termbox.AttrUnderline, bg)
+				break
+			}
+		}
+		x += width
+		return true
+	})
+}
@@ -0,0 +1,218 @@
+package termboxui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// Graphics is a way of showing pictures in the terminal. Besides half block
+// characters, which work anywhere with colour, some terminals can show
+// images at full resolution with a graphics protocol. Images shown that way
+// are written straight to the terminal, over the top of termbox's cells,
+// once termbox has flushed.
+type Graphics int
+
+const (
+	GraphicsBlocks Graphics = iota // Half block characters
+	GraphicsKitty                  // Kitty's graphics protocol
+	GraphicsSixel                  // DEC sixels
+)
+
+var graphicsNames = map[string]Graphics{
+	"blocks": GraphicsBlocks,
+	"kitty":  GraphicsKitty,
+	"sixel":  GraphicsSixel,
+}
+
+// ParseGraphics returns the Graphics called name, or the one DetectGraphics
+// finds for "auto"
+func ParseGraphics(name string) (Graphics, error) {
+	if name == "auto" {
+		return DetectGraphics(), nil
+	}
+	if g, ok := graphicsNames[name]; ok {
+		return g, nil
+	}
+	return GraphicsBlocks, fmt.Errorf("No such graphics %q, try auto, blocks, kitty or sixel", name)
+}
+
+// DetectGraphics guesses the best Graphics the terminal supports from the
+// environment. Asking the terminal would mean reading its answer from under
+// termbox, so only terminals known by name are picked out.
+func DetectGraphics() Graphics {
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty":
+		return GraphicsKitty
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return GraphicsSixel
+	}
+	return GraphicsBlocks
+}
+
+// The size in pixels of a character cell, when the terminal won't say
+const (
+	defaultCellW = 10
+	defaultCellH = 20
+)
+
+// moveCursor returns the escape sequence moving the cursor to cell x, y
+func moveCursor(x, y int) string {
+	return fmt.Sprintf("\x1b[%d;%dH", y+1, x+1)
+}
+
+// PlaceImage writes img to w, scaled to fill r, using graphics g. The cells
+// in r should be left blank, for the image to show through. It does nothing
+// for GraphicsBlocks, which Picture.Draw draws instead.
+func PlaceImage(w io.Writer, r Rect, img image.Image, g Graphics) error {
+	if r.W <= 0 || r.H <= 0 {
+		return nil
+	}
+	switch g {
+	case GraphicsKitty:
+		return placeKitty(w, r, img)
+	case GraphicsSixel:
+		cw, ch := cellSize()
+		return placeSixel(w, r, scaleImage(img, r.W*cw, r.H*ch))
+	}
+	return nil
+}
+
+// ClearImages removes any images placed with graphics g. Sixels are part of
+// the text on the screen, so they're only gone once termbox.Sync has
+// redrawn every cell.
+func ClearImages(w io.Writer, g Graphics) error {
+	if g == GraphicsKitty {
+		_, err := io.WriteString(w, "\x1b_Ga=d,q=2\x1b\\")
+		return err
+	}
+	return nil
+}
+
+// kittyChunk is the most base64 the kitty protocol takes in one escape code
+const kittyChunk = 4096
+
+// placeKitty sends img as a PNG for kitty to scale to r, replacing any image
+// already shown. q=2 stops kitty replying, which termbox would take as keys.
+func placeKitty(w io.Writer, r Rect, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	out := bufio.NewWriter(w)
+	fmt.Fprint(out, "\x1b_Ga=d,q=2\x1b\\", "\x1b7", moveCursor(r.X, r.Y))
+	for first := true; first || data != ""; first = false {
+		chunk := data
+		if len(chunk) > kittyChunk {
+			chunk = chunk[:kittyChunk]
+		}
+		data = data[len(chunk):]
+		more := 0
+		if data != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(out, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", r.W, r.H, more, chunk)
+		} else {
+			fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	fmt.Fprint(out, "\x1b8")
+	return out.Flush()
+}
+
+// placeSixel draws img as sixels with its top left corner at r's, in the 216
+// colours of a 6x6x6 colour cube
+func placeSixel(w io.Writer, r Rect, img image.Image) error {
+	b := img.Bounds()
+	out := bufio.NewWriter(w)
+	fmt.Fprint(out, "\x1b7", moveCursor(r.X, r.Y))
+	fmt.Fprintf(out, "\x1bPq\"1;1;%d;%d", b.Dx(), b.Dy())
+	for c := 0; c < 216; c++ {
+		fmt.Fprintf(out, "#%d;2;%d;%d;%d", c, c/36*20, c/6%6*20, c%6*20)
+	}
+	// Each band of sixels is six pixels tall. Each colour in it gets a row of
+	// sixels, with a bit set for each pixel of that colour, drawn over the
+	// same band with $ before moving down to the next with -.
+	cube := func(v uint32) int { return int(v>>8) * 6 / 256 }
+	rows := make(map[int][]byte)
+	for y0 := b.Min.Y; y0 < b.Max.Y; y0 += 6 {
+		for c := range rows {
+			delete(rows, c)
+		}
+		var order []int
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for bit := 0; bit < 6 && y0+bit < b.Max.Y; bit++ {
+				pr, pg, pb, _ := img.At(x, y0+bit).RGBA()
+				c := cube(pr)*36 + cube(pg)*6 + cube(pb)
+				row, ok := rows[c]
+				if !ok {
+					row = make([]byte, b.Dx())
+					order = append(order, c)
+				}
+				row[x-b.Min.X] |= 1 << uint(bit)
+				rows[c] = row
+			}
+		}
+		for i, c := range order {
+			if i > 0 {
+				out.WriteByte('$')
+			}
+			fmt.Fprintf(out, "#%d", c)
+			writeSixelRow(out, rows[c])
+		}
+		out.WriteByte('-')
+	}
+	fmt.Fprint(out, "\x1b\\", "\x1b8")
+	return out.Flush()
+}
+
+// writeSixelRow writes a row of sixels, run length encoding repeats
+func writeSixelRow(out *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		n := 1
+		for i+n < len(row) && row[i+n] == row[i] {
+			n++
+		}
+		ch := byte(63 + row[i])
+		if n > 3 {
+			fmt.Fprintf(out, "!%d%c", n, ch)
+		} else {
+			for j := 0; j < n; j++ {
+				out.WriteByte(ch)
+			}
+		}
+		i += n
+	}
+}
+
+// scaleImage returns img scaled to w by h pixels, averaging the pixels which
+// fall within each new one
+func scaleImage(img image.Image, w, h int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			r, g, bl := averageColour(img, image.Rect(x0, y0, x1, y1))
+			i := out.PixOffset(x, y)
+			out.Pix[i], out.Pix[i+1], out.Pix[i+2], out.Pix[i+3] = uint8(r), uint8(g), uint8(bl), 0xff
+		}
+	}
+	return out
+}
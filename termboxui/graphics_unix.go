@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package termboxui
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cellSize returns the size in pixels of a character cell, asking the
+// terminal through TIOCGWINSZ. Not every terminal fills in the pixel size, so
+// it falls back to a guess.
+func cellSize() (w, h int) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(),
+		uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Row == 0 || ws.Col == 0 || ws.Xpixel == 0 || ws.Ypixel == 0 {
+		return defaultCellW, defaultCellH
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row)
+}
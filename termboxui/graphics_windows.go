@@ -0,0 +1,7 @@
+package termboxui
+
+// cellSize returns a guess at the size in pixels of a character cell, as
+// the Windows console can't say
+func cellSize() (w, h int) {
+	return defaultCellW, defaultCellH
+}
@@ -0,0 +1,255 @@
+package termboxui
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// Rect is an area of the screen, with its top left corner at X, Y
+type Rect struct {
+	X, Y, W, H int
+}
+
+// Contains returns whether the cell at x, y is within r
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// Size is how much of a Split a pane wants. A pane with neither Fixed nor
+// Percent set shares out whatever the others leave.
+type Size struct {
+	Fixed   int // Cells
+	Percent int // Of the space left by the fixed size panes and dividers
+	Min     int // The pane is never made smaller than this; without it, fixed sizes never shrink
+}
+
+// Fixed returns a Size of n cells
+func Fixed(n int) Size { return Size{Fixed: n} }
+
+// Percent returns a Size of p percent of the space left over
+func Percent(p int) Size { return Size{Percent: p} }
+
+// Flex returns a Size which takes whatever space is left
+func Flex() Size { return Size{} }
+
+// AtLeast returns s with a minimum of n cells
+func (s Size) AtLeast(n int) Size {
+	s.Min = n
+	return s
+}
+
+// floor returns the smallest a pane of size s can be squeezed to. Fixed
+// sizes don't shrink unless they have a minimum.
+func (s Size) floor() int {
+	if s.Min == 0 {
+		return s.Fixed
+	}
+	return s.Min
+}
+
+// Direction is which way a Split lays out its panes
+type Direction int
+
+const (
+	Horizontal Direction = iota // Side by side
+	Vertical                    // One above another
+)
+
+// Pane is one of the parts a Split divides its area into
+type Pane struct {
+	Size   Size
+	Hidden bool
+}
+
+// Split divides an area into panes, side by side or one above another, with
+// dividing lines between them if Dividers is set
+type Split struct {
+	Dir      Direction
+	Panes    []Pane
+	Dividers bool
+}
+
+// NewSplit returns a Split in direction dir with a pane of each size
+func NewSplit(dir Direction, dividers bool, sizes ...Size) *Split {
+	s := &Split{Dir: dir, Dividers: dividers}
+	for _, size := range sizes {
+		s.Panes = append(s.Panes, Pane{Size: size})
+	}
+	return s
+}
+
+// Layout works out where each pane goes within r. Hidden panes get an empty
+// Rect. It returns false if r is too small to give every pane its minimum.
+func (s *Split) Layout(r Rect) ([]Rect, bool) {
+	rects := make([]Rect, len(s.Panes))
+	var shown []int
+	for i, p := range s.Panes {
+		if !p.Hidden {
+			shown = append(shown, i)
+		}
+	}
+	if len(shown) == 0 {
+		return rects, true
+	}
+	total := r.W
+	if s.Dir == Vertical {
+		total = r.H
+	}
+	avail := total
+	if s.Dividers {
+		avail -= len(shown) - 1
+	}
+
+	sizes := make([]int, len(s.Panes))
+	left := avail // What's left after fixed sizes, for percentages
+	for _, i := range shown {
+		left -= s.Panes[i].Size.Fixed
+	}
+	if left < 0 {
+		left = 0
+	}
+	var flexes []int
+	used := 0
+	for _, i := range shown {
+		size := s.Panes[i].Size
+		switch {
+		case size.Fixed > 0:
+			sizes[i] = size.Fixed
+		case size.Percent > 0:
+			sizes[i] = left * size.Percent / 100
+		default:
+			flexes = append(flexes, i)
+		}
+		used += sizes[i]
+	}
+	// Flex panes share the rest, and the last takes any odd cells
+	for n, i := range flexes {
+		share := (avail - used) / (len(flexes) - n)
+		if share < 0 {
+			share = 0
+		}
+		sizes[i] = share
+		used += share
+	}
+	for _, i := range shown {
+		if min := s.Panes[i].Size.Min; sizes[i] < min {
+			used += min - sizes[i]
+			sizes[i] = min
+		}
+	}
+
+	// Give any space still spare to the last pane which can grow, or take
+	// back any overspend from the last panes first, down to their minimums
+	if used < avail {
+		grow := shown[len(shown)-1]
+		for _, i := range shown {
+			if s.Panes[i].Size.Fixed == 0 {
+				grow = i
+			}
+		}
+		sizes[grow] += avail - used
+		used = avail
+	}
+	for n := len(shown) - 1; n >= 0 && used > avail; n-- {
+		i := shown[n]
+		spare := sizes[i] - s.Panes[i].Size.floor()
+		if spare > used-avail {
+			spare = used - avail
+		}
+		if spare > 0 {
+			sizes[i] -= spare
+			used -= spare
+		}
+	}
+
+	pos := 0
+	for _, i := range shown {
+		if s.Dir == Horizontal {
+			rects[i] = Rect{r.X + pos, r.Y, sizes[i], r.H}
+		} else {
+			rects[i] = Rect{r.X, r.Y + pos, r.W, sizes[i]}
+		}
+		pos += sizes[i]
+		if s.Dividers {
+			pos++
+		}
+	}
+	return rects, used <= avail
+}
+
+// DrawDividers draws the lines between the panes laid out at rects
+func (s *Split) DrawDividers(rects []Rect) {
+	if !s.Dividers {
+		return
+	}
+	style := StyleOf(RoleBorder)
+	for _, d := range s.DividerRects(rects) {
+		if s.Dir == Horizontal {
+			Drawbox(d.X, d.Y, 1, d.H, "")
+			continue
+		}
+		for x := d.X; x < d.X+d.W; x++ {
+			termbox.SetCell(x, d.Y, '─', style.Fg, style.Bg)
+		}
+	}
+}
+
+// DividerRects returns where the lines between the panes laid out at rects go
+func (s *Split) DividerRects(rects []Rect) (dividers []Rect) {
+	if !s.Dividers {
+		return nil
+	}
+	shown := 0
+	for i, r := range rects {
+		if s.Panes[i].Hidden {
+			continue
+		}
+		if shown > 0 {
+			if s.Dir == Horizontal {
+				dividers = append(dividers, Rect{r.X - 1, r.Y, 1, r.H})
+			} else {
+				dividers = append(dividers, Rect{r.X, r.Y - 1, r.W, 1})
+			}
+		}
+		shown++
+	}
+	return
+}
+
+// Resize grows pane i by delta, in cells if it has a fixed size or
+// percentage points if it has a percentage. It won't go below its minimum.
+func (s *Split) Resize(i, delta int) {
+	size := &s.Panes[i].Size
+	min := size.Min
+	if min < 1 {
+		min = 1
+	}
+	switch {
+	case size.Fixed > 0:
+		size.Fixed += delta
+		if size.Fixed < min {
+			size.Fixed = min
+		}
+	case size.Percent > 0:
+		size.Percent += delta
+		if size.Percent < 1 {
+			size.Percent = 1
+		} else if size.Percent > 100 {
+			size.Percent = 100
+		}
+	}
+}
+
+// Toggle hides pane i, or shows it if it's hidden
+func (s *Split) Toggle(i int) {
+	s.Panes[i].Hidden = !s.Panes[i].Hidden
+}
+
+// DrawTooSmall fills r with a message saying it's too small to draw in,
+// for when a layout won't fit
+func DrawTooSmall(r Rect) {
+	style := StyleOf(RoleDim)
+	for y := r.Y; y < r.Y+r.H; y++ {
+		Fill(r.X, y, r.W, StyleOf(RoleNormal))
+	}
+	Printc(r.X+r.W/2, r.Y+r.H/2, style.Fg, style.Bg, Truncate("Too small", r.W))
+}
@@ -0,0 +1,126 @@
+package termboxui
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// markKeys are the keys HandleMarkKey handles
+var markKeys = []listKey{
+	{keys: []termbox.Key{termbox.KeySpace}, name: "space", help: "Mark or unmark, and move down", run: func(l *ScrollList, _ int) {
+		l.ToggleMark()
+	}},
+	{ch: 'V', name: "V", help: "Start marking a range, or mark up to here", run: func(l *ScrollList, _ int) {
+		l.ToggleRange()
+	}},
+	{keys: []termbox.Key{termbox.KeyCtrlA}, name: "ctrl-a", help: "Mark everything shown, or unmark it all", run: func(l *ScrollList, _ int) {
+		l.MarkAll(!l.allMarked())
+	}},
+}
+
+// MarkKeys are the keys HandleMarkKey handles
+var MarkKeys = listKeyHelp(markKeys)
+
+// HandleMarkKey handles keys for marking items, returning whether ev was one
+// of them. Only items shown by the filter are marked, but marks on hidden
+// items are kept. The keys are in markKeys.
+func (l *ScrollList) HandleMarkKey(ev termbox.Event) bool {
+	return l.runListKey(markKeys, ev, 0)
+}
+
+// ToggleMark marks the selected item, or unmarks it if it is marked, and
+// moves the selection down
+func (l *ScrollList) ToggleMark() {
+	if !l.SelectionShown() {
+		return
+	}
+	l.Items[l.Selected].Marked = !l.Items[l.Selected].Marked
+	l.SelectDown()
+}
+
+// ToggleRange starts a range at the selected item. Called again, it marks
+// every item shown between there and the selection.
+func (l *ScrollList) ToggleRange() {
+	if !l.ranging {
+		l.ranging, l.anchor = true, l.Selected
+		return
+	}
+	for _, index := range l.rangeItems() {
+		l.Items[index].Marked = true
+	}
+	l.ranging = false
+}
+
+// MarkAll marks, or unmarks, every enabled item shown
+func (l *ScrollList) MarkAll(marked bool) {
+	for row := 0; row < l.rowCount(); row++ {
+		if index := l.itemAt(row); !l.Items[index].Disabled {
+			l.Items[index].Marked = marked
+		}
+	}
+	l.ranging = false
+}
+
+// ClearMarks unmarks every item, including hidden ones, and abandons any range
+func (l *ScrollList) ClearMarks() {
+	for i := range l.Items {
+		l.Items[i].Marked = false
+	}
+	l.ranging = false
+}
+
+// MarkedItems returns the indexes of the marked items, in order, including
+// those in a range which has been started but not finished
+func (l *ScrollList) MarkedItems() (marked []int) {
+	inrange := map[int]bool{}
+	for _, index := range l.rangeItems() {
+		inrange[index] = true
+	}
+	for i, item := range l.Items {
+		if item.Marked || inrange[i] {
+			marked = append(marked, i)
+		}
+	}
+	return
+}
+
+// rangeItems returns the enabled items shown between the range's start and
+// the selection, if a range has been started
+func (l *ScrollList) rangeItems() (items []int) {
+	if !l.ranging || l.anchor >= len(l.Items) {
+		return nil
+	}
+	from, to := l.rowOf(l.anchor), l.rowOf(l.Selected)
+	if from < 0 { // The start has been filtered out
+		from = 0
+	}
+	if from > to {
+		from, to = to, from
+	}
+	for row := from; row <= to && row < l.rowCount(); row++ {
+		if index := l.itemAt(row); !l.Items[index].Disabled {
+			items = append(items, index)
+		}
+	}
+	return
+}
+
+// inRange returns whether item index is within a range being marked
+func (l *ScrollList) inRange(index int) bool {
+	if !l.ranging || l.anchor >= len(l.Items) {
+		return false
+	}
+	row, from, to := l.rowOf(index), l.rowOf(l.anchor), l.rowOf(l.Selected)
+	if from > to {
+		from, to = to, from
+	}
+	return row >= 0 && row >= from && row <= to
+}
+
+func (l *ScrollList) allMarked() bool {
+	for row := 0; row < l.rowCount(); row++ {
+		if item := l.Items[l.itemAt(row)]; !item.Disabled && !item.Marked {
+			return false
+		}
+	}
+	return true
+}
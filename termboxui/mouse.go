@@ -0,0 +1,56 @@
+package termboxui
+
+import (
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Two clicks on the same item closer together than this make a double click
+var DoubleClickTime = 400 * time.Millisecond
+
+// MouseAction is what a mouse event did to a ScrollList
+type MouseAction int
+
+const (
+	MouseNone      MouseAction = iota // The event wasn't over the list
+	MouseScrolled                     // The wheel scrolled the list
+	MouseSelected                     // An item was clicked, and is now selected
+	MouseActivated                    // An item was double clicked
+)
+
+// HandleMouse handles a mouse event over the list where it was last drawn:
+// the wheel scrolls it, and clicking an enabled item selects it.
+func (l *ScrollList) HandleMouse(ev termbox.Event) MouseAction {
+	if ev.Type != termbox.EventMouse || ev.Mod&termbox.ModMotion != 0 || !l.Contains(ev.MouseX, ev.MouseY) {
+		return MouseNone
+	}
+	switch ev.Key {
+	case termbox.MouseWheelUp:
+		l.ScrollBy(-3)
+		return MouseScrolled
+	case termbox.MouseWheelDown:
+		l.ScrollBy(3)
+		return MouseScrolled
+	case termbox.MouseLeft:
+		row := l.offset + ev.MouseY - l.rect.Y
+		if row >= l.rowCount() || l.Items[l.itemAt(row)].Disabled {
+			return MouseSelected
+		}
+		index := l.itemAt(row)
+		now := time.Now()
+		double := index == l.lastclick && now.Sub(l.lastclickat) < DoubleClickTime
+		l.Selected, l.lastclick, l.lastclickat = index, index, now
+		if double {
+			l.lastclick = -1 // A third click starts over
+			return MouseActivated
+		}
+		return MouseSelected
+	}
+	return MouseNone
+}
+
+// Contains returns whether the cell at x, y is within the list, as last drawn
+func (l *ScrollList) Contains(x, y int) bool {
+	return l.rect.Contains(x, y)
+}
@@ -0,0 +1,242 @@
+package termboxui
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// KeyHelp describes what a key, or keys, do, for showing in help
+type KeyHelp struct {
+	Keys string
+	Help string
+}
+
+// AddKeyHelp appends the help for keys to help, adding them to the last line
+// instead if it does the same thing, so pairs such as j and k share a line
+func AddKeyHelp(help []KeyHelp, keys, text string) []KeyHelp {
+	if n := len(help); n > 0 && help[n-1].Help == text {
+		help[n-1].Keys += " " + keys
+		return help
+	}
+	return append(help, KeyHelp{Keys: keys, Help: text})
+}
+
+// A listKey is a key a ScrollList handles, and what it does given the count
+// typed before it, which is 0 if there wasn't one
+type listKey struct {
+	keys []termbox.Key // Special keys, such as termbox.KeyPgdn
+	ch   rune          // And a character
+	name string        // How help shows the key
+	help string
+	run  func(l *ScrollList, count int)
+}
+
+// matches returns whether ev is a press of k
+func (k *listKey) matches(ev termbox.Event) bool {
+	if ev.Ch != 0 {
+		return ev.Ch == k.ch
+	}
+	for _, key := range k.keys {
+		if ev.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// runListKey runs the key in keys which ev is a press of, returning whether
+// there was one
+func (l *ScrollList) runListKey(keys []listKey, ev termbox.Event, count int) bool {
+	for i := range keys {
+		if keys[i].matches(ev) {
+			keys[i].run(l, count)
+			return true
+		}
+	}
+	return false
+}
+
+func listKeyHelp(keys []listKey) (help []KeyHelp) {
+	for _, k := range keys {
+		help = AddKeyHelp(help, k.name, k.help)
+	}
+	return
+}
+
+// orOne returns count, or 1 if no count was typed
+func orOne(count int) int {
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// navKeys are the keys HandleKey handles, besides counts
+var navKeys = []listKey{
+	{keys: []termbox.Key{termbox.KeyArrowDown}, ch: 'j', name: "j ↓", help: "Down, up", run: func(l *ScrollList, count int) {
+		l.SelectBy(orOne(count))
+	}},
+	{keys: []termbox.Key{termbox.KeyArrowUp}, ch: 'k', name: "k ↑", help: "Down, up", run: func(l *ScrollList, count int) {
+		l.SelectBy(-orOne(count))
+	}},
+	{keys: []termbox.Key{termbox.KeyCtrlD}, name: "ctrl-d", help: "Half a page down, up", run: func(l *ScrollList, count int) {
+		l.ScrollBy(orOne(count) * l.page() / 2)
+	}},
+	{keys: []termbox.Key{termbox.KeyCtrlU}, name: "ctrl-u", help: "Half a page down, up", run: func(l *ScrollList, count int) {
+		l.ScrollBy(-orOne(count) * l.page() / 2)
+	}},
+	{keys: []termbox.Key{termbox.KeyCtrlF, termbox.KeyPgdn}, name: "ctrl-f pgdn", help: "A page down, up", run: func(l *ScrollList, count int) {
+		l.ScrollBy(orOne(count) * l.page())
+	}},
+	{keys: []termbox.Key{termbox.KeyCtrlB, termbox.KeyPgup}, name: "ctrl-b pgup", help: "A page down, up", run: func(l *ScrollList, count int) {
+		l.ScrollBy(-orOne(count) * l.page())
+	}},
+	// HandleKey waits for the second g
+	{keys: []termbox.Key{termbox.KeyHome}, ch: 'g', name: "gg home", help: "Top, bottom; with a count, go to that row", run: func(l *ScrollList, count int) {
+		if count > 0 {
+			l.selectRow(count-1, 1)
+		} else {
+			l.SelectTop()
+		}
+	}},
+	{keys: []termbox.Key{termbox.KeyEnd}, ch: 'G', name: "G end", help: "Top, bottom; with a count, go to that row", run: func(l *ScrollList, count int) {
+		if count > 0 {
+			l.selectRow(count-1, 1)
+		} else {
+			l.SelectBottom()
+		}
+	}},
+	{ch: 'p', name: "p", help: "Go to what's playing", run: func(l *ScrollList, count int) {
+		l.SelectHighlit()
+	}},
+	{ch: 'n', name: "n", help: "Next, previous match of the last filter", run: func(l *ScrollList, count int) {
+		for i := 0; i < orOne(count); i++ {
+			l.NextMatch(1)
+		}
+	}},
+	{ch: 'N', name: "N", help: "Next, previous match of the last filter", run: func(l *ScrollList, count int) {
+		for i := 0; i < orOne(count); i++ {
+			l.NextMatch(-1)
+		}
+	}},
+}
+
+// ListKeys are the keys HandleKey handles
+var ListKeys = append(listKeyHelp(navKeys), KeyHelp{"1-9", "Count for the next movement, e.g. 5j"})
+
+// HandleKey handles vim style navigation keys for the list, returning whether ev
+// was one of them. A count typed beforehand, as in 5j, repeats the movement.
+// The keys are in navKeys.
+func (l *ScrollList) HandleKey(ev termbox.Event) bool {
+	counted, pending := l.count, l.pending
+	l.count, l.pending = 0, 0
+	if ev.Ch >= '1' && ev.Ch <= '9' || ev.Ch == '0' && counted > 0 {
+		l.count = counted*10 + int(ev.Ch-'0')
+		return true
+	}
+	if ev.Ch == 'g' && pending != 'g' {
+		// Wait for the second g, holding on to the count
+		l.pending, l.count = 'g', counted
+		return true
+	}
+	return l.runListKey(navKeys, ev, counted)
+}
+
+// page returns the number of rows shown when the list was last drawn
+func (l *ScrollList) page() int {
+	if l.rect.H < 1 {
+		return 1
+	}
+	return l.rect.H
+}
+
+// SelectBy moves the selection n rows down, or up if n is negative, skipping
+// disabled items and stopping at the top or bottom of the list
+func (l *ScrollList) SelectBy(n int) {
+	for ; n > 0; n-- {
+		l.SelectDown()
+	}
+	for ; n < 0; n++ {
+		l.SelectUp()
+	}
+}
+
+// ScrollBy scrolls the list n rows down, or up if n is negative, taking the
+// selection with it so it stays in the same place on screen
+func (l *ScrollList) ScrollBy(n int) {
+	dir := 1
+	if n < 0 {
+		dir = -1
+	}
+	row := l.rowOf(l.Selected)
+	l.offset += n
+	if l.offset > l.rowCount()-l.page() {
+		l.offset = l.rowCount() - l.page()
+	}
+	if l.offset < 0 {
+		l.offset = 0
+	}
+	l.selectRow(row+n, dir)
+}
+
+// SelectTop selects the first enabled item
+func (l *ScrollList) SelectTop() {
+	l.selectRow(0, 1)
+}
+
+// SelectBottom selects the last enabled item
+func (l *ScrollList) SelectBottom() {
+	l.selectRow(l.rowCount()-1, -1)
+}
+
+// SelectHighlit selects the highlit item, if it is shown
+func (l *ScrollList) SelectHighlit() {
+	if l.rowOf(l.Highlit) >= 0 {
+		l.Selected = l.Highlit
+	}
+}
+
+// NextMatch selects the next item, or the previous if dir is -1, which matches
+// the last filter used, wrapping around the ends of the list. This works after
+// the filter has been cleared, too.
+func (l *ScrollList) NextMatch(dir int) {
+	words := strings.Fields(l.search)
+	n := len(l.Items)
+	if len(words) == 0 || n == 0 {
+		return
+	}
+	for i := 1; i <= n; i++ {
+		index := ((l.Selected+dir*i)%n + n) % n
+		item := l.Items[index]
+		fields := append([]string{item.TextL, item.TextR}, item.Cells...)
+		if _, ok := matchFields(words, fields); ok && !item.Disabled && l.rowOf(index) >= 0 {
+			l.Selected = index
+			return
+		}
+	}
+}
+
+// selectRow selects the item in row, or if it is disabled the nearest enabled
+// item in direction dir (1 for down, -1 for up), or failing that the other way.
+// Rows past either end of the list are taken to mean the end.
+func (l *ScrollList) selectRow(row, dir int) {
+	rows := l.rowCount()
+	if rows == 0 {
+		return
+	}
+	if row >= rows {
+		row = rows - 1
+	}
+	if row < 0 {
+		row = 0
+	}
+	for _, d := range []int{dir, -dir} {
+		for r := row; r >= 0 && r < rows; r += d {
+			if !l.Items[l.itemAt(r)].Disabled {
+				l.Selected = l.itemAt(r)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package termboxui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+// testList returns a list of n items, as if last drawn h rows high
+func testList(n, h int) *ScrollList {
+	l := NewScrollList()
+	items := make([]ListItem, n)
+	for i := range items {
+		items[i].TextL = fmt.Sprintf("item %d", i)
+	}
+	l.SetItems(items)
+	l.rect = Rect{W: 20, H: h}
+	return &l
+}
+
+// press sends keys to l, each a rune or a termbox.Key
+func press(l *ScrollList, keys ...interface{}) {
+	for _, k := range keys {
+		switch k := k.(type) {
+		case rune:
+			l.HandleKey(termbox.Event{Type: termbox.EventKey, Ch: k})
+		case termbox.Key:
+			l.HandleKey(termbox.Event{Type: termbox.EventKey, Key: k})
+		}
+	}
+}
+
+// runes returns the runes of s, for press
+func runes(s string) []interface{} {
+	keys := make([]interface{}, 0, len(s))
+	for _, r := range s {
+		keys = append(keys, r)
+	}
+	return keys
+}
+
+func TestHandleKeyCounts(t *testing.T) {
+	tests := []struct {
+		start int
+		keys  string
+		want  int
+	}{
+		{0, "j", 1},
+		{0, "5j", 5},
+		{0, "12j", 12},
+		{0, "10j", 10},
+		{12, "3k", 9},
+		{5, "20k", 0},
+		{0, "500j", 49},
+		{20, "gg", 0},
+		{20, "5gg", 4},
+		{0, "G", 49},
+		{0, "7G", 6},
+		{0, "99G", 49},
+		{0, "3x2j", 2}, // Another key drops the count
+	}
+	for _, test := range tests {
+		l := testList(50, 10)
+		l.Selected = test.start
+		press(l, runes(test.keys)...)
+		if l.Selected != test.want {
+			t.Errorf("%q from %d selected %d, want %d", test.keys, test.start, l.Selected, test.want)
+		}
+	}
+}
+
+func TestHandleKeyUnhandled(t *testing.T) {
+	l := testList(10, 5)
+	if l.HandleKey(termbox.Event{Ch: '0'}) {
+		t.Error("0 without a count was handled")
+	}
+	if l.HandleKey(termbox.Event{Ch: 'x'}) {
+		t.Error("x was handled")
+	}
+	if !l.HandleKey(termbox.Event{Ch: '1'}) || !l.HandleKey(termbox.Event{Ch: '0'}) || l.count != 10 {
+		t.Errorf("1 then 0 left a count of %d, want 10", l.count)
+	}
+}
+
+func TestSelectSkipsDisabled(t *testing.T) {
+	l := testList(5, 5)
+	l.Items[1].Disabled = true
+	l.Items[2].Disabled = true
+	press(l, 'j')
+	if l.Selected != 3 {
+		t.Errorf("j over disabled items selected %d, want 3", l.Selected)
+	}
+	press(l, 'k')
+	if l.Selected != 0 {
+		t.Errorf("k over disabled items selected %d, want 0", l.Selected)
+	}
+	l.Items[4].Disabled = true
+	press(l, 'G')
+	if l.Selected != 3 {
+		t.Errorf("G with the last item disabled selected %d, want 3", l.Selected)
+	}
+}
+
+func TestScrollBy(t *testing.T) {
+	tests := []struct {
+		selected, offset, n int
+		wantsel, wantoffset int
+	}{
+		{3, 0, 10, 13, 10},
+		{13, 10, -10, 3, 0},
+		{3, 0, -10, 0, 0},    // Already at the top, so only the selection moves
+		{95, 90, 10, 99, 90}, // Already at the bottom
+		{85, 80, 10, 95, 90}, // The offset stops at the last page
+	}
+	for _, test := range tests {
+		l := testList(100, 10)
+		l.Selected, l.offset = test.selected, test.offset
+		l.ScrollBy(test.n)
+		if l.Selected != test.wantsel || l.offset != test.wantoffset {
+			t.Errorf("ScrollBy(%d) from %d at %d: selected %d at %d, want %d at %d", test.n,
+				test.selected, test.offset, l.Selected, l.offset, test.wantsel, test.wantoffset)
+		}
+	}
+}
+
+func TestPageKeys(t *testing.T) {
+	tests := []struct {
+		keys                []interface{}
+		wantsel, wantoffset int
+	}{
+		{[]interface{}{termbox.KeyCtrlD}, 7, 5},
+		{[]interface{}{termbox.KeyCtrlD, termbox.KeyCtrlU}, 2, 0},
+		{[]interface{}{'2', termbox.KeyCtrlD}, 12, 10},
+		{[]interface{}{termbox.KeyCtrlF}, 12, 10},
+		{[]interface{}{termbox.KeyPgdn}, 12, 10},
+		{[]interface{}{termbox.KeyCtrlF, termbox.KeyCtrlB}, 2, 0},
+		{[]interface{}{termbox.KeyPgdn, termbox.KeyPgup}, 2, 0},
+		{[]interface{}{'3', termbox.KeyCtrlF}, 32, 30},
+		{[]interface{}{'9', termbox.KeyCtrlF}, 49, 40},
+	}
+	for _, test := range tests {
+		l := testList(50, 10)
+		l.Selected = 2
+		press(l, test.keys...)
+		if l.Selected != test.wantsel || l.offset != test.wantoffset {
+			t.Errorf("%v selected %d at %d, want %d at %d", test.keys,
+				l.Selected, l.offset, test.wantsel, test.wantoffset)
+		}
+	}
+}
+
+func TestHomeEnd(t *testing.T) {
+	l := testList(50, 10)
+	press(l, termbox.KeyEnd)
+	if l.Selected != 49 {
+		t.Errorf("end selected %d, want 49", l.Selected)
+	}
+	press(l, termbox.KeyHome)
+	if l.Selected != 0 {
+		t.Errorf("home selected %d, want 0", l.Selected)
+	}
+}
+
+func TestNextMatch(t *testing.T) {
+	l := testList(0, 10)
+	l.SetItems([]ListItem{
+		{TextL: "apple"}, {TextL: "banana"}, {TextL: "avocado"},
+		{TextL: "cherry"}, {TextL: "apricot"}, {TextL: "grape"},
+	})
+	// n and N keep working with the last filter after it's cleared
+	l.SetFilter("ap")
+	l.SetFilter("")
+	tests := []struct {
+		start int
+		keys  string
+		want  int
+	}{
+		{0, "n", 4},  // apricot
+		{4, "n", 5},  // grape
+		{5, "n", 0},  // Wraps round to apple
+		{0, "N", 5},  // Wraps back to grape
+		{5, "N", 4},  // apricot
+		{0, "2n", 5}, // Past apricot to grape
+		{1, "n", 4},  // From a non-match
+	}
+	for _, test := range tests {
+		l.Selected = test.start
+		press(l, runes(test.keys)...)
+		if l.Selected != test.want {
+			t.Errorf("%q from %d selected %d, want %d", test.keys, test.start, l.Selected, test.want)
+		}
+	}
+
+	// Matches hidden by a different filter are skipped
+	l.search = "ap"
+	l.filter = "grape"
+	l.refilter()
+	l.Selected = 5
+	press(l, 'n')
+	if l.Selected != 5 {
+		t.Errorf("n with only grape shown selected %d, want 5", l.Selected)
+	}
+}
+
+func TestNextMatchNoSearch(t *testing.T) {
+	l := testList(5, 5)
+	l.Selected = 2
+	press(l, 'n')
+	if l.Selected != 2 {
+		t.Errorf("n before any filter selected %d, want 2", l.Selected)
+	}
+}
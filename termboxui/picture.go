@@ -0,0 +1,107 @@
+package termboxui
+
+import (
+	"image"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Picture is an image for drawing in the terminal. Drawn with half block
+// characters, each cell shows two pixels, one above the other, which makes
+// the pixels about square. The image is scaled and matched to the terminal's
+// colours once for each size it's drawn at.
+type Picture struct {
+	img    image.Image
+	w, h   int                 // Size in cells it was last scaled to
+	pixels []termbox.Attribute // Colours of each pixel at that size, a row at a time
+}
+
+// NewPicture returns a Picture of img
+func NewPicture(img image.Image) *Picture {
+	return &Picture{img: img}
+}
+
+// Image returns the picture's image
+func (p *Picture) Image() image.Image {
+	return p.img
+}
+
+// Fit returns the size, in cells, of the largest the picture can be drawn
+// within w by h cells, keeping its shape
+func (p *Picture) Fit(w, h int) (int, int) {
+	b := p.img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 || w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	// Work in pixels, two to a cell vertically
+	pw, ph := w, 2*h
+	if pw*b.Dy() > ph*b.Dx() {
+		pw = ph * b.Dx() / b.Dy()
+	} else {
+		ph = pw * b.Dy() / b.Dx()
+	}
+	if pw < 1 {
+		pw = 1
+	}
+	return pw, (ph + 1) / 2
+}
+
+// Draw draws the picture as large as it fits in r, centred, and returns
+// where it went
+func (p *Picture) Draw(r Rect) Rect {
+	w, h := p.Fit(r.W, r.H)
+	at := Rect{r.X + (r.W-w)/2, r.Y + (r.H-h)/2, w, h}
+	if w == 0 {
+		return at
+	}
+	if w != p.w || h != p.h {
+		p.scale(w, h)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			top, bottom := p.pixels[2*y*w+x], p.pixels[(2*y+1)*w+x]
+			termbox.SetCell(at.X+x, at.Y+y, '▀', top, bottom)
+		}
+	}
+	return at
+}
+
+// scale works out the colour of each pixel of the picture at w by h cells,
+// averaging the image's pixels which fall within each
+func (p *Picture) scale(w, h int) {
+	p.w, p.h = w, h
+	p.pixels = make([]termbox.Attribute, w*2*h)
+	b := p.img.Bounds()
+	for y := 0; y < 2*h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/(2*h), b.Min.Y+(y+1)*b.Dy()/(2*h)
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			r, g, bl := averageColour(p.img, image.Rect(x0, y0, x1, y1))
+			p.pixels[y*w+x] = nearestColour(r, g, bl)
+		}
+	}
+}
+
+// averageColour returns the average red, green and blue, from 0 to 255, of
+// the pixels of img within r
+func averageColour(img image.Image, r image.Rectangle) (red, green, blue int) {
+	r = r.Intersect(img.Bounds())
+	n := r.Dx() * r.Dy()
+	if n == 0 {
+		return
+	}
+	var rs, gs, bs uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			pr, pg, pb, _ := img.At(x, y).RGBA()
+			rs, gs, bs = rs+uint64(pr), gs+uint64(pg), bs+uint64(pb)
+		}
+	}
+	return int(rs / uint64(n) >> 8), int(gs / uint64(n) >> 8), int(bs / uint64(n) >> 8)
+}
@@ -1,43 +1,152 @@
 package termboxui
 
 import (
-	"unicode/utf8"
+	"strings"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
+	"github.com/rivo/uniseg"
 )
 
-// Print sets a line of cells starting at x,y to the string msg
-func Print(x, y int, fg, bg termbox.Attribute, msg string) {
-	for _, aRune := range msg {
-		termbox.SetCell(x, y, aRune, fg, bg)
-		x++
+// Ellipsis replaces whatever Printlim and Truncate cut off the end of a string
+const Ellipsis = "…"
+
+// Text is printed a grapheme cluster (what a reader would call a character) at
+// a time, so combining marks and emoji sequences stay in one piece. Termbox
+// cells hold a single rune, so only the first rune of each cluster is drawn;
+// that's the base character, and the rest are marks it can't show anyway.
+
+// clusterWidth returns the number of cells the grapheme cluster runes takes up
+func clusterWidth(runes []rune) int {
+	w := runewidth.RuneWidth(runes[0])
+	if len(runes) == 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+		return 2 // A flag
+	}
+	for _, r := range runes[1:] {
+		if r == '\uFE0F' && w == 1 { // Variation selector asking for emoji presentation
+			w = 2
+		}
+	}
+	return w
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// eachCluster calls f with each grapheme cluster in s, its width and its
+// first rune's index in s counted in runes, until f returns false
+func eachCluster(s string, f func(runes []rune, width, index int) bool) {
+	g := uniseg.NewGraphemes(s)
+	index := 0
+	for g.Next() {
+		runes := g.Runes()
+		if !f(runes, clusterWidth(runes), index) {
+			return
+		}
+		index += len(runes)
+	}
+}
+
+// eachVisualCluster is eachCluster, but in the order the clusters are drawn
+// from left to right, which differs for right-to-left text
+func eachVisualCluster(s string, f func(runes []rune, width, index int) bool) {
+	var clusters []cluster
+	eachCluster(s, func(runes []rune, width, index int) bool {
+		clusters = append(clusters, cluster{runes, width, index})
+		return true
+	})
+	for _, c := range visualOrder(clusters) {
+		if !f(c.runes, c.width, c.index) {
+			return
+		}
+	}
+}
+
+// StringWidth returns the number of cells s takes up when printed
+func StringWidth(s string) (w int) {
+	eachCluster(s, func(_ []rune, width, _ int) bool {
+		w += width
+		return true
+	})
+	return
+}
+
+// Truncate shortens s to fit in w cells, cutting between grapheme clusters
+// and ending it with an Ellipsis if anything was cut off
+func Truncate(s string, w int) string {
+	if StringWidth(s) <= w {
+		return s
+	}
+	limit := w - StringWidth(Ellipsis)
+	if limit < 0 {
+		return ""
+	}
+	used, end := 0, 0
+	eachCluster(s, func(runes []rune, width, _ int) bool {
+		if used+width > limit {
+			return false
+		}
+		used += width
+		end += len(string(runes))
+		return true
+	})
+	return s[:end] + Ellipsis
+}
+
+// Wrap breaks s into lines of at most w cells, between words where it can.
+// Newlines in s start a new line, and words wider than w are truncated.
+func Wrap(s string, w int) (lines []string) {
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			word = Truncate(word, w)
+			switch {
+			case line == "":
+				line = word
+			case StringWidth(line)+1+StringWidth(word) <= w:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
 	}
+	return
+}
+
+// setCell sets a cell of termbox's back buffer; tests swap it out to see
+// what's printed
+var setCell = termbox.SetCell
+
+// Print sets a line of cells starting at x,y to the string msg, with any
+// right-to-left text in it reordered (see ReorderRTL)
+func Print(x, y int, fg, bg termbox.Attribute, msg string) {
+	eachVisualCluster(msg, func(runes []rune, width, _ int) bool {
+		if width > 0 {
+			setCell(x, y, runes[0], fg, bg)
+			x += width
+		}
+		return true
+	})
 }
 
 // Printr sets a line of cells ending at x, y to the string msg.
 // Useful for right-aligning text.
 func Printr(x, y int, fg, bg termbox.Attribute, msg string) {
-	i := utf8.RuneCountInString(msg)
-	for _, aRune := range msg {
-		termbox.SetCell(x-i, y, aRune, fg, bg)
-		i--
-	}
+	Print(x-StringWidth(msg), y, fg, bg, msg)
 }
 
 // Printc sets a line of cells centered around x, y to the string msg.
 // Useful for center-aligning text.
 func Printc(x, y int, fg, bg termbox.Attribute, msg string) {
-	offset := utf8.RuneCountInString(msg) / 2
-	for pos, aRune := range msg {
-		termbox.SetCell(x-offset+pos, y, aRune, fg, bg)
-	}
+	Print(x-StringWidth(msg)/2, y, fg, bg, msg)
 }
 
-// Printlim is a wrapper around Print which limits the length of the printed message
-// to lim, replacing the last two runes with "..". Useful for columnular layouts.
+// Printlim is a wrapper around Print which limits the printed message to lim
+// cells, ending it with an Ellipsis if it is cut short. Useful for columnular
+// layouts.
 func Printlim(x, y int, fg, bg termbox.Attribute, msg string, lim int) {
-	if utf8.RuneCountInString(msg) > lim {
-		msg = msg[:lim-2] + ".."
-	}
-	Print(x, y, fg, bg, msg)
+	Print(x, y, fg, bg, Truncate(msg, lim))
 }
@@ -0,0 +1,147 @@
+package termboxui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"日本語", 6},
+		{"한국어", 6},
+		{"Ａ", 2},       // Fullwidth Latin
+		{"e\u0301", 1}, // Combining acute accent
+		{"e\u0301e\u0301e\u0301", 3},
+		{"שָׁלוֹם", 4}, // Hebrew with points
+		{"مرحبا", 5},   // Arabic
+		{"👍", 2},
+		{"👍🏽", 2},              // Skin tone modifier
+		{"👩\u200d👩\u200d👧", 2}, // ZWJ family
+		{"❤️", 2},              // Emoji presentation
+		{"🇬🇧", 2},              // Flag
+		{"\u200fabc", 3},       // Right-to-left mark
+		{"Björk – 東京 שלום 👩\u200d👩\u200d👧", 20},
+	}
+	for _, test := range tests {
+		if w := StringWidth(test.s); w != test.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", test.s, w, test.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		w    int
+		want string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "ab…"},
+		{"abc", 0, ""},
+		{"abc", 1, "…"},
+		{"日本語", 6, "日本語"},
+		{"日本語", 5, "日本…"},
+		{"日本語", 4, "日…"}, // Half a character won't fit, so it's left out
+		{"e\u0301e\u0301e\u0301", 2, "e\u0301…"},
+		{"👩\u200d👩\u200d👧👩\u200d👩\u200d👧", 3, "👩\u200d👩\u200d👧…"},
+		{"👩\u200d👩\u200d👧👩\u200d👩\u200d👧", 2, "…"},
+		{"🇬🇧🇫🇷", 3, "🇬🇧…"},
+		{"שלום עולם", 5, "שלום…"},
+		{"مرحبا بالعالم", 6, "مرحبا…"},
+	}
+	for _, test := range tests {
+		got := Truncate(test.s, test.w)
+		if got != test.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", test.s, test.w, got, test.want)
+		}
+		if w := StringWidth(got); w > test.w {
+			t.Errorf("Truncate(%q, %d) is %d wide", test.s, test.w, w)
+		}
+	}
+}
+
+// reorderRTL sets ReorderRTL for the rest of the test, whatever the terminal
+// running the tests does
+func reorderRTL(t *testing.T, reorder bool) {
+	saved := ReorderRTL
+	ReorderRTL = reorder
+	t.Cleanup(func() { ReorderRTL = saved })
+}
+
+// printed returns what f prints, as a line of cells, with wide characters
+// followed by a cell of "_"
+func printed(w int, f func()) string {
+	cells := []rune(strings.Repeat(" ", w))
+	setCell = func(x, y int, ch rune, fg, bg termbox.Attribute) {
+		if x >= 0 && x < w {
+			cells[x] = ch
+			if runeWidth := clusterWidth([]rune{ch}); runeWidth == 2 && x+1 < w {
+				cells[x+1] = '_'
+			}
+		}
+	}
+	defer func() { setCell = termbox.SetCell }()
+	f()
+	return string(cells)
+}
+
+func TestPrintlim(t *testing.T) {
+	reorderRTL(t, true)
+	tests := []struct {
+		s    string
+		lim  int
+		want string
+	}{
+		{"abcdef", 4, "abc…      "},
+		{"日本語テキスト", 7, "日_本_語_…   "},
+		{"e\u0301te\u0301", 5, "ete       "}, // Only the base characters fit in cells
+		{"👩\u200d👩\u200d👧 family", 6, "👩_ fa…    "},
+		{"ab שלום cd", 10, "ab םולש cd"},
+		{"שלום עולם", 10, "םלוע םולש "},
+		{"שלום עולם", 6, "… םולש    "},
+		{"שיר 2024", 10, "2024 ריש  "},
+		{"אבג (1)", 10, "(1) גבא   "},
+	}
+	for _, test := range tests {
+		got := printed(10, func() {
+			Printlim(0, 0, 0, 0, test.s, test.lim)
+		})
+		if got != test.want {
+			t.Errorf("Printlim(%q, %d) printed %q, want %q", test.s, test.lim, got, test.want)
+		}
+	}
+}
+
+func TestPrintAlignment(t *testing.T) {
+	reorderRTL(t, true)
+	tests := []struct {
+		name  string
+		print func()
+		want  string
+	}{
+		{"Printr", func() { Printr(10, 0, 0, 0, "日本") }, "      日_本_"},
+		{"Printr RTL", func() { Printr(10, 0, 0, 0, "שלום") }, "      םולש"},
+		{"Printr mark", func() { Printr(10, 0, 0, 0, "\u200fשלום") }, "      םולש"},
+		{"Printc", func() { Printc(5, 0, 0, 0, "日本") }, "   日_本_   "},
+		{"Printc RTL", func() { Printc(5, 0, 0, 0, "אבגד") }, "   דגבא   "},
+	}
+	for _, test := range tests {
+		if got := printed(10, test.print); got != test.want {
+			t.Errorf("%s printed %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReorderRTLOff(t *testing.T) {
+	reorderRTL(t, false)
+	if got := printed(4, func() { Print(0, 0, 0, 0, "אבג") }); got != "אבג " {
+		t.Errorf("Print with ReorderRTL off printed %q, want %q", got, "אבג ")
+	}
+}
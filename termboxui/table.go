@@ -0,0 +1,142 @@
+package termboxui
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// Column describes a column of a Table. Columns with a Weight share out the width
+// left over by the fixed Width columns, in proportion to their weights, but are
+// never narrower than MinWidth. When the table is too narrow for every column,
+// columns are dropped from the right until the rest fit.
+type Column struct {
+	Title      string
+	Width      int
+	Weight     int
+	MinWidth   int
+	AlignRight bool
+}
+
+// Table is a ScrollList whose items are laid out in columns under a header row.
+// Each item's Cells holds its text for each column.
+type Table struct {
+	ScrollList
+	Columns    []Column
+	SortColumn int // Column the items are sorted by, marked in the header, or -1
+	SortDesc   bool
+}
+
+// NewTable returns a new Table with the given columns
+func NewTable(columns []Column) Table {
+	return Table{ScrollList: NewScrollList(), Columns: columns, SortColumn: -1}
+}
+
+// Widths returns the width of each column when the table is drawn w cells wide,
+// with a one cell gap between columns. Dropped columns have a width of zero.
+func (t *Table) Widths(w int) []int {
+	widths := make([]int, len(t.Columns))
+	shown := len(t.Columns)
+	for ; shown > 0; shown-- {
+		need := shown - 1 // Gaps between columns
+		for _, c := range t.Columns[:shown] {
+			if c.Weight > 0 {
+				need += c.MinWidth
+			} else {
+				need += c.Width
+			}
+		}
+		if need <= w {
+			break
+		}
+	}
+	spare := w - (shown - 1)
+	weights := 0
+	for _, c := range t.Columns[:shown] {
+		if c.Weight == 0 {
+			spare -= c.Width
+		} else {
+			weights += c.Weight
+		}
+	}
+	// Columns whose share would be under their MinWidth get their MinWidth,
+	// and the rest share out what's left, until every share is big enough
+	pinned := make([]bool, shown)
+	for again := true; again; {
+		again = false
+		for i, c := range t.Columns[:shown] {
+			if c.Weight > 0 && !pinned[i] && spare*c.Weight/weights < c.MinWidth {
+				pinned[i] = true
+				widths[i] = c.MinWidth
+				spare -= c.MinWidth
+				weights -= c.Weight
+				again = weights > 0
+			}
+		}
+	}
+	for i, c := range t.Columns[:shown] {
+		if c.Weight == 0 {
+			widths[i] = c.Width
+			continue
+		}
+		if pinned[i] {
+			continue
+		}
+		// The last weighted column takes any remainder from rounding down
+		widths[i] = spare * c.Weight / weights
+		spare -= widths[i]
+		weights -= c.Weight
+	}
+	return widths
+}
+
+// Draw draws the header row at y and the items beneath it. See ScrollList.Draw.
+func (t *Table) Draw(x, y, w, h int, focussed bool) {
+	if w < 0 || h < 1 {
+		return
+	}
+	widths := t.Widths(w)
+	header := StyleOf(RoleHeader)
+	Fill(x, y, w, header)
+	t.drawCells(x, y, widths, header.Fg, header.Bg, t.headings(), nil)
+	t.drawItems(x, y+1, w, h-1, focussed, func(index int, item ListItem, y int, fg, bg termbox.Attribute) {
+		var matched [][]int
+		if m := t.Matched(index); len(m) > 2 {
+			matched = m[2:] // Skip TextL and TextR
+		}
+		t.drawCells(x, y, widths, fg, bg, item.Cells, matched)
+	})
+}
+
+// headings returns the column titles, with the sort column marked by an arrow
+func (t *Table) headings() []string {
+	headings := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		headings[i] = c.Title
+		if i == t.SortColumn {
+			if t.SortDesc {
+				headings[i] += "▼"
+			} else {
+				headings[i] += "▲"
+			}
+		}
+	}
+	return headings
+}
+
+// drawCells draws a row of cells, highlighting the runes in each cell at the
+// positions in matched
+func (t *Table) drawCells(x, y int, widths []int, fg, bg termbox.Attribute, cells []string, matched [][]int) {
+	for i, width := range widths {
+		if width == 0 || i >= len(cells) {
+			continue
+		}
+		cellx := x
+		if cw := StringWidth(cells[i]); t.Columns[i].AlignRight && cw < width {
+			cellx = x + width - cw
+		}
+		Printlim(cellx, y, fg, bg, cells[i], width)
+		if i < len(matched) {
+			highlightMatches(cellx, y, fg, bg, cells[i], width, matched[i])
+		}
+		x += width + 1
+	}
+}
@@ -0,0 +1,43 @@
+package termboxui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableWidths(t *testing.T) {
+	table := NewTable([]Column{
+		{Width: 4},
+		{Weight: 3, MinWidth: 12},
+		{Weight: 2, MinWidth: 10},
+		{Weight: 2, MinWidth: 10},
+		{Weight: 1, MinWidth: 8},
+	})
+	tests := []struct {
+		w    int
+		want []int
+	}{
+		{10, []int{4, 0, 0, 0, 0}},
+		{30, []int{4, 14, 10, 0, 0}},
+		{39, []int{4, 12, 10, 10, 0}},
+		{40, []int{4, 13, 10, 10, 0}}, // Sharing by weight alone would make Title 11
+		{48, []int{4, 12, 10, 10, 8}},
+		{60, []int{4, 18, 13, 13, 8}},
+		{120, []int{4, 42, 28, 28, 14}},
+	}
+	for _, test := range tests {
+		got := table.Widths(test.w)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Widths(%d) = %v, want %v", test.w, got, test.want)
+		}
+		total := 0
+		for _, width := range got {
+			if width > 0 {
+				total += width + 1
+			}
+		}
+		if total-1 > test.w {
+			t.Errorf("Widths(%d) = %v, which is %d wide", test.w, got, total-1)
+		}
+	}
+}
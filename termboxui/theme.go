@@ -0,0 +1,331 @@
+package termboxui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Style is how a piece of the UI is drawn: colours, plus attributes such as
+// termbox.AttrBold in Fg
+type Style struct {
+	Fg, Bg termbox.Attribute
+}
+
+// Attrs returns the style's attributes, such as AttrBold, without its colour
+func (s Style) Attrs() termbox.Attribute {
+	return s.Fg &^ colourMask
+}
+
+// Role names a piece of the UI which a Theme gives a Style to
+type Role string
+
+const (
+	RoleNormal        Role = "normal"          // Ordinary text
+	RoleDim           Role = "dim"             // Disabled items and hints
+	RoleBorder        Role = "border"          // Boxes and dividing lines
+	RoleBar           Role = "bar"             // The top bar
+	RoleTitle         Role = "title"           // The name in the top bar
+	RoleNowPlaying    Role = "nowplaying"      // The now playing bar
+	RoleHeader        Role = "header"          // Table headings
+	RoleSelected      Role = "selected"        // The selected item of an unfocussed list
+	RoleSelectedFocus Role = "selected_focus"  // The selected item of the focussed list
+	RoleMarked        Role = "marked"          // Marked items
+	RoleMarkedCursor  Role = "marked_selected" // The selected item when it is marked
+	RoleHighlit       Role = "highlit"         // Whatever is playing; only the foreground is used
+	RoleAccent        Role = "accent"          // Picked out text, e.g. the chosen EQ band
+	RoleGood          Role = "good"            // e.g. "Logged In"
+	RoleWarning       Role = "warning"
+	RoleError         Role = "error" // Errors, and status messages
+	RoleLogo          Role = "logo"
+	RoleMeter         Role = "meter"
+)
+
+// Roles lists every Role, for checking themes and listing them
+var Roles = []Role{
+	RoleNormal, RoleDim, RoleBorder, RoleBar, RoleTitle, RoleNowPlaying,
+	RoleHeader, RoleSelected, RoleSelectedFocus, RoleMarked, RoleMarkedCursor,
+	RoleHighlit, RoleAccent, RoleGood, RoleWarning, RoleError, RoleLogo, RoleMeter,
+}
+
+// Theme gives each Role a Style. Roles it leaves out are drawn as RoleNormal.
+type Theme struct {
+	Name   string
+	Styles map[Role]Style
+}
+
+// Style returns the theme's style for role
+func (t *Theme) Style(role Role) Style {
+	if s, ok := t.Styles[role]; ok {
+		return s
+	}
+	return t.Styles[RoleNormal]
+}
+
+// The built in themes. Dark is the original look of Spot.
+var (
+	DarkTheme = &Theme{Name: "dark", Styles: map[Role]Style{
+		RoleNormal:        {termbox.ColorWhite, termbox.ColorDefault},
+		RoleDim:           {termbox.ColorDefault, termbox.ColorDefault},
+		RoleBorder:        {termbox.ColorWhite, termbox.ColorDefault},
+		RoleBar:           {termbox.ColorWhite, termbox.ColorBlack},
+		RoleTitle:         {termbox.AttrBold, termbox.ColorBlack},
+		RoleNowPlaying:    {termbox.ColorBlue, termbox.ColorBlack},
+		RoleHeader:        {termbox.ColorWhite | termbox.AttrBold, termbox.ColorBlack},
+		RoleSelected:      {termbox.ColorWhite, termbox.ColorBlack},
+		RoleSelectedFocus: {termbox.ColorYellow, termbox.ColorBlack},
+		RoleMarked:        {termbox.ColorWhite, termbox.ColorBlue},
+		RoleMarkedCursor:  {termbox.ColorYellow, termbox.ColorCyan},
+		RoleHighlit:       {termbox.ColorBlue, termbox.ColorDefault},
+		RoleAccent:        {termbox.ColorYellow, termbox.ColorDefault},
+		RoleGood:          {termbox.ColorGreen, termbox.ColorDefault},
+		RoleWarning:       {termbox.ColorYellow, termbox.ColorDefault},
+		RoleError:         {termbox.ColorRed, termbox.ColorDefault},
+		RoleLogo:          {termbox.ColorGreen, termbox.ColorDefault},
+		RoleMeter:         {termbox.ColorBlue, termbox.ColorDefault},
+	}}
+	LightTheme = &Theme{Name: "light", Styles: map[Role]Style{
+		RoleNormal:        {termbox.ColorBlack, termbox.ColorDefault},
+		RoleDim:           {termbox.ColorDefault, termbox.ColorDefault},
+		RoleBorder:        {termbox.ColorBlack, termbox.ColorDefault},
+		RoleBar:           {termbox.ColorBlack, termbox.ColorWhite},
+		RoleTitle:         {termbox.ColorBlack | termbox.AttrBold, termbox.ColorWhite},
+		RoleNowPlaying:    {termbox.ColorBlue, termbox.ColorWhite},
+		RoleHeader:        {termbox.ColorBlack | termbox.AttrBold, termbox.ColorWhite},
+		RoleSelected:      {termbox.ColorBlack, termbox.ColorWhite},
+		RoleSelectedFocus: {termbox.ColorMagenta | termbox.AttrBold, termbox.ColorWhite},
+		RoleMarked:        {termbox.ColorBlack, termbox.ColorCyan},
+		RoleMarkedCursor:  {termbox.ColorMagenta | termbox.AttrBold, termbox.ColorCyan},
+		RoleHighlit:       {termbox.ColorBlue, termbox.ColorDefault},
+		RoleAccent:        {termbox.ColorMagenta, termbox.ColorDefault},
+		RoleGood:          {termbox.ColorGreen, termbox.ColorDefault},
+		RoleWarning:       {termbox.ColorYellow, termbox.ColorDefault},
+		RoleError:         {termbox.ColorRed, termbox.ColorDefault},
+		RoleLogo:          {termbox.ColorGreen, termbox.ColorDefault},
+		RoleMeter:         {termbox.ColorBlue, termbox.ColorDefault},
+	}}
+	MonoTheme = &Theme{Name: "mono", Styles: map[Role]Style{
+		RoleNormal:        {termbox.ColorDefault, termbox.ColorDefault},
+		RoleBar:           {termbox.AttrReverse, termbox.ColorDefault},
+		RoleTitle:         {termbox.AttrReverse | termbox.AttrBold, termbox.ColorDefault},
+		RoleNowPlaying:    {termbox.AttrReverse, termbox.ColorDefault},
+		RoleHeader:        {termbox.AttrReverse | termbox.AttrBold, termbox.ColorDefault},
+		RoleSelected:      {termbox.AttrReverse, termbox.ColorDefault},
+		RoleSelectedFocus: {termbox.AttrReverse | termbox.AttrBold, termbox.ColorDefault},
+		RoleMarked:        {termbox.AttrUnderline, termbox.ColorDefault},
+		RoleMarkedCursor:  {termbox.AttrReverse | termbox.AttrUnderline, termbox.ColorDefault},
+		RoleHighlit:       {termbox.AttrBold, termbox.ColorDefault},
+		RoleAccent:        {termbox.AttrBold, termbox.ColorDefault},
+		RoleError:         {termbox.AttrBold, termbox.ColorDefault},
+		RoleLogo:          {termbox.AttrBold, termbox.ColorDefault},
+	}}
+)
+
+// Themes holds the themes which can be used, by name
+var Themes = map[string]*Theme{
+	DarkTheme.Name:  DarkTheme,
+	LightTheme.Name: LightTheme,
+	MonoTheme.Name:  MonoTheme,
+}
+
+// ThemeNames returns the names of Themes in alphabetical order
+func ThemeNames() []string {
+	var names []string
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var current = DarkTheme
+
+// colourMask picks out the colour from an Attribute, leaving off AttrBold etc.
+const colourMask = 0x1ff
+
+// SetTheme sets the theme everything is drawn with from now on
+func SetTheme(t *Theme) {
+	current = t
+}
+
+// CurrentTheme returns the theme everything is being drawn with
+func CurrentTheme() *Theme {
+	return current
+}
+
+// StyleOf returns the current theme's style for role
+func StyleOf(role Role) Style {
+	return current.Style(role)
+}
+
+// Colours is how many colours spot uses, 8 or 256. The version of termbox
+// spot is built with has no truecolour output, so it's 256 at most, even in
+// truecolour terminals.
+var Colours = 8
+
+// InitColours switches termbox to 256 colour output if the terminal looks
+// like it supports it, which truecolour terminals all do. Call it after
+// termbox.Init, before loading any themes.
+func InitColours() {
+	term, colorterm := os.Getenv("TERM"), os.Getenv("COLORTERM")
+	if strings.Contains(term, "256color") || colorterm == "truecolor" || colorterm == "24bit" {
+		termbox.SetOutputMode(termbox.Output256)
+		Colours = 256
+	}
+}
+
+// The basic colours, by name. In 256 colour mode these are the first 8
+// colours of the palette, so they work in either mode.
+var colourNames = map[string]termbox.Attribute{
+	"default": termbox.ColorDefault,
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+var attrNames = map[string]termbox.Attribute{
+	"bold":      termbox.AttrBold,
+	"underline": termbox.AttrUnderline,
+	"reverse":   termbox.AttrReverse,
+}
+
+// ParseColour parses a colour name such as "blue", a palette number from 0
+// to 255, or a "#rrggbb" colour. Colours that can't be shown are matched to
+// the nearest that can, so "#rrggbb" colours are rounded to the 256 colour
+// palette, or to the basic 8 colours, even in truecolour terminals.
+func ParseColour(s string) (termbox.Attribute, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := colourNames[s]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(s, "#") && len(s) == 7 {
+		rgb, err := strconv.ParseUint(s[1:], 16, 32)
+		if err == nil {
+			return nearestColour(int(rgb>>16), int(rgb>>8&0xff), int(rgb&0xff)), nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < 256 {
+		if n >= Colours {
+			return nearestColour(paletteRGB(n)), nil
+		}
+		return termbox.Attribute(n + 1), nil
+	}
+	return 0, fmt.Errorf("Bad colour %q", s)
+}
+
+// paletteRGB returns the red, green and blue of colour n of the xterm 256
+// colour palette
+func paletteRGB(n int) (r, g, b int) {
+	switch {
+	case n == 7:
+		return 0xc0, 0xc0, 0xc0
+	case n == 8:
+		return 0x80, 0x80, 0x80
+	case n < 16:
+		level := 0x80
+		if n > 8 {
+			level, n = 0xff, n-8
+		}
+		return level * (n & 1), level * (n >> 1 & 1), level * (n >> 2 & 1)
+	case n < 232:
+		steps := []int{0, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+		n -= 16
+		return steps[n/36], steps[n/6%6], steps[n%6]
+	default:
+		grey := 8 + (n-232)*10
+		return grey, grey, grey
+	}
+}
+
+// nearestColour returns whichever colour the terminal can show is closest
+// to r, g, b
+func nearestColour(r, g, b int) termbox.Attribute {
+	best, bestdist := 0, -1
+	for n := 0; n < Colours; n++ {
+		pr, pg, pb := paletteRGB(n)
+		dist := (r-pr)*(r-pr) + (g-pg)*(g-pg) + (b-pb)*(b-pb)
+		if bestdist < 0 || dist < bestdist {
+			best, bestdist = n, dist
+		}
+	}
+	return termbox.Attribute(best + 1)
+}
+
+// themeFile is the layout of a theme file. A theme starts off as a copy of
+// its base theme, and styles in the file override the base's.
+type themeFile struct {
+	Base   string `json:"base"`
+	Styles map[Role]struct {
+		Fg    string   `json:"fg"`
+		Bg    string   `json:"bg"`
+		Attrs []string `json:"attrs"`
+	} `json:"styles"`
+}
+
+// ParseTheme parses a JSON theme called name, such as
+//
+//	{"base": "dark", "styles": {"nowplaying": {"fg": "#ffaf00", "attrs": ["bold"]}}}
+//
+// Colours are as for ParseColour, and any left out are the base theme's.
+func ParseTheme(name string, data []byte) (*Theme, error) {
+	var f themeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Base == "" {
+		f.Base = DarkTheme.Name
+	}
+	base, ok := Themes[f.Base]
+	if !ok {
+		return nil, fmt.Errorf("No such base theme %q", f.Base)
+	}
+	t := &Theme{Name: name, Styles: map[Role]Style{}}
+	for role, style := range base.Styles {
+		t.Styles[role] = style
+	}
+	known := map[Role]bool{}
+	for _, role := range Roles {
+		known[role] = true
+	}
+	for role, spec := range f.Styles {
+		if !known[role] {
+			return nil, fmt.Errorf("No such style %q", role)
+		}
+		style := base.Style(role)
+		if spec.Fg != "" {
+			c, err := ParseColour(spec.Fg)
+			if err != nil {
+				return nil, err
+			}
+			style.Fg = c
+		} else if spec.Attrs != nil {
+			style.Fg &^= termbox.AttrBold | termbox.AttrUnderline | termbox.AttrReverse
+		}
+		if spec.Bg != "" {
+			c, err := ParseColour(spec.Bg)
+			if err != nil {
+				return nil, err
+			}
+			style.Bg = c
+		}
+		for _, a := range spec.Attrs {
+			attr, ok := attrNames[a]
+			if !ok {
+				return nil, fmt.Errorf("No such attribute %q", a)
+			}
+			style.Fg |= attr
+		}
+		t.Styles[role] = style
+	}
+	return t, nil
+}
@@ -1,24 +1,43 @@
 package termboxui
 
 import (
+	"time"
+
 	"github.com/nsf/termbox-go"
 )
 
 // ListItem is an item in a ScrollList's list. TextL and TextR are displayed in the list,
-// aligned to the left and right respectively, and Data is an optional integer
+// aligned to the left and right respectively, and Data is an optional integer. In a
+// Table, Cells holds the text for each column instead. Marked items are picked
+// out for acting on together.
 type ListItem struct {
 	TextL    string
 	TextR    string
 	Data     int
 	Disabled bool
+	Marked   bool
+	Cells    []string
 }
 
-// ScrollList is a scrollable list of items.
+// ScrollList is a scrollable list of items. Selected and Highlit are indexes into
+// Items, whether or not the list is filtered.
 type ScrollList struct {
-	Items    []ListItem
-	Selected int
-	Highlit  int
-	offset   int
+	Items       []ListItem
+	Selected    int
+	Highlit     int
+	offset      int // Row at the top of the list, counting only rows shown by the filter
+	filter      string
+	view        []int           // Indexes of the items matching the filter, nil if unfiltered
+	viewlen     int             // len(Items) when view was last worked out
+	matches     map[int][][]int // Matched rune positions of each field of each item, by index
+	search      string          // The last non-empty filter, for NextMatch
+	count       int             // Count typed before a navigation key
+	pending     rune            // First key of a two key command, e.g. gg
+	rect        Rect            // Where the items were last drawn, for paging and the mouse
+	lastclick   int             // Item last clicked, and when, for spotting double clicks
+	lastclickat time.Time
+	ranging     bool // Whether a range is being marked, from anchor to Selected
+	anchor      int
 }
 
 // NewScrollList returns, you guessed it, a new ScrollList instance
@@ -30,106 +49,224 @@ func NewScrollList() ScrollList {
 // left corner (x and y) and a width and height (w and h), as well as a focussed bool which
 // Changes the color scheme to indicate that the list is focussed on screen
 func (l *ScrollList) Draw(x, y, w, h int, focussed bool) {
+	l.drawItems(x, y, w, h, focussed, func(index int, item ListItem, y int, fg, bg termbox.Attribute) {
+		Printlim(x, y, fg, bg, item.TextL, w)
+		Printr(x+w, y, fg, bg, item.TextR)
+		matched := l.Matched(index)
+		if len(matched) > 1 {
+			highlightMatches(x, y, fg, bg, item.TextL, w, matched[0])
+			highlightMatches(x+w-StringWidth(item.TextR), y, fg, bg, item.TextR, w, matched[1])
+		}
+	})
+}
+
+// drawItems scrolls the list to keep the selection in view, and draws the
+// background of each visible item before calling drawitem to draw its text
+// in the given colours
+func (l *ScrollList) drawItems(x, y, w, h int, focussed bool, drawitem func(index int, item ListItem, y int, fg, bg termbox.Attribute)) {
 	// The no. of lines kept in view above/below selection when scrolling up/down
 	scrollpadding := 2
 	if w < 0 || h < 0 {
 		return
 	}
+	l.rect = Rect{x, y, w, h}
+	rows := l.rowCount()
+	selected := l.rowOf(l.Selected)
 	//Recalculate offset to keep selection in view
 	switch {
-	case l.Selected >= (h+l.offset)-scrollpadding-1 && l.offset+h < len(l.Items):
-		l.offset += (l.Selected - ((h + l.offset) - scrollpadding - 1))
-	case l.Selected <= l.offset+scrollpadding && l.offset > 0:
-		l.offset -= (l.offset - l.Selected) + scrollpadding
-	}
-	displayed := l.Items[l.offset:]
-	for i, tr := range displayed {
-		if i == h {
-			break
-		}
-		index := i + l.offset
-		fgcolor, bgcolor := termbox.ColorWhite, termbox.ColorDefault
+	case selected >= (h+l.offset)-scrollpadding-1 && l.offset+h < rows:
+		l.offset += (selected - ((h + l.offset) - scrollpadding - 1))
+	case selected <= l.offset+scrollpadding && l.offset > 0:
+		l.offset -= (l.offset - selected) + scrollpadding
+	}
+	if l.offset > rows {
+		l.offset = rows
+	}
+	if l.offset < 0 {
+		l.offset = 0
+	}
+	for i := 0; i < h && l.offset+i < rows; i++ {
+		index := l.itemAt(l.offset + i)
+		tr := l.Items[index]
+		style := StyleOf(RoleNormal)
 		if tr.Disabled {
-			fgcolor = termbox.ColorDefault
+			style = StyleOf(RoleDim)
 		} else {
-			if index == l.Selected {
-				bgcolor = termbox.ColorBlack
-				if focussed {
-					fgcolor = termbox.ColorYellow
-				}
+			marked := tr.Marked || l.inRange(index)
+			switch {
+			case index == l.Selected && marked:
+				style = StyleOf(RoleMarkedCursor)
+			case index == l.Selected && focussed:
+				style = StyleOf(RoleSelectedFocus)
+			case index == l.Selected:
+				style = StyleOf(RoleSelected)
+			case marked:
+				style = StyleOf(RoleMarked)
 			}
-			if index == l.Highlit {
-				fgcolor = termbox.ColorBlue
+			// Show what's playing, unless it wouldn't show up on this background
+			if hl := StyleOf(RoleHighlit); index == l.Highlit {
+				if hl.Fg&colourMask == termbox.ColorDefault { // Only attributes
+					style.Fg |= hl.Fg
+				} else if hl.Fg&colourMask != style.Bg&colourMask {
+					style.Fg = hl.Fg
+				}
 			}
 		}
-		Drawbar(x, y+i, w, bgcolor)
-		Printlim(x, y+i, fgcolor, bgcolor, tr.TextL, w)
-		Printr(x+w, y+i, fgcolor, bgcolor, tr.TextR)
+		Fill(x, y+i, w, style)
+		drawitem(index, tr, y+i, style.Fg, style.Bg)
 	}
 }
 
 // SelectDown moves the item selection down one, skipping any disabled items
 func (l *ScrollList) SelectDown() {
+	row := l.rowOf(l.Selected)
 	for {
-		if l.Selected >= len(l.Items)-1 { // Already at (or past!) the bottom
-			l.Selected = len(l.Items) - 1 // Reset it just in case (eg list items removed)
+		if row >= l.rowCount()-1 { // Already at (or past!) the bottom
 			break
 		}
-		l.Selected++
-		if !l.Items[l.Selected].Disabled { // If current item is disabled we loop again
+		row++
+		if !l.Items[l.itemAt(row)].Disabled { // If current item is disabled we loop again
+			l.Selected = l.itemAt(row)
 			break
 		}
 	}
+	if l.Selected >= len(l.Items) { // Reset it just in case (eg list items removed)
+		l.Selected = len(l.Items) - 1
+	}
+	if l.Selected < 0 { // An empty list still has its selection at the top
+		l.Selected = 0
+	}
 }
 
 // SelectUp moves the item selection up one, skipping any disabled items
 func (l *ScrollList) SelectUp() {
+	row := l.rowOf(l.Selected)
 	for {
-		if l.Selected == 0 { // Already at the top
+		if row <= 0 { // Already at the top
 			break
 		}
-		l.Selected--
-		if !l.Items[l.Selected].Disabled { // If current item is disabled we loop again
+		row--
+		if !l.Items[l.itemAt(row)].Disabled { // If current item is disabled we loop again
+			l.Selected = l.itemAt(row)
 			break
 		}
 	}
 }
 
-// Clear clears a ScrollList and resets selection/highlit
+// SetItems replaces the list's items, keeping the current filter
+func (l *ScrollList) SetItems(items []ListItem) {
+	l.Items = items
+	if l.anchor >= len(items) {
+		l.ranging = false
+	}
+	l.refilter()
+}
+
+// Clear clears a ScrollList and resets selection/highlit and the filter
 func (l *ScrollList) Clear() {
 	l.Items = nil
 	l.Selected = 0
 	l.Highlit = -1
 	l.offset = 0
+	l.ranging = false
+	l.SetFilter("")
 }
 
 // Draw a box with top left corner at x,y height/width h,w and (optional) title title.
 // Can also be used to draw lines with a w/h of 1.
 func Drawbox(x, y, w, h int, title string) {
+	fg, bg := StyleOf(RoleBorder).Fg, StyleOf(RoleBorder).Bg
 
 	for i := 0; i < w; i++ {
-		termbox.SetCell(x+i, y, '─', termbox.ColorWhite, termbox.ColorDefault)
-		termbox.SetCell(x+i, y+h-1, '─', termbox.ColorWhite, termbox.ColorDefault)
+		termbox.SetCell(x+i, y, '─', fg, bg)
+		termbox.SetCell(x+i, y+h-1, '─', fg, bg)
 	}
 	for i := 0; i < h; i++ {
-		termbox.SetCell(x, y+i, '│', termbox.ColorWhite, termbox.ColorDefault)
-		termbox.SetCell(x+w-1, y+i, '│', termbox.ColorWhite, termbox.ColorDefault)
+		termbox.SetCell(x, y+i, '│', fg, bg)
+		termbox.SetCell(x+w-1, y+i, '│', fg, bg)
 	}
 	if title != "" {
-		Print(x+1, y, termbox.ColorWhite, termbox.ColorDefault, "["+title+"]")
+		Print(x+1, y, fg, bg, "["+title+"]")
 	}
 	if w > 1 {
-		termbox.SetCell(x, y, '┌', termbox.ColorWhite, termbox.ColorDefault)
-		termbox.SetCell(x, y+h-1, '└', termbox.ColorWhite, termbox.ColorDefault)
-		termbox.SetCell(x+w-1, y, '┐', termbox.ColorWhite, termbox.ColorDefault)
-		termbox.SetCell(x+w-1, y+h-1, '┘', termbox.ColorWhite, termbox.ColorDefault)
+		termbox.SetCell(x, y, '┌', fg, bg)
+		termbox.SetCell(x, y+h-1, '└', fg, bg)
+		termbox.SetCell(x+w-1, y, '┐', fg, bg)
+		termbox.SetCell(x+w-1, y+h-1, '┘', fg, bg)
 	}
 }
 
 // Draw a bar across w columns of row y of the screen starting at col x
 // with the background color bg
 func Drawbar(x, y, w int, bg termbox.Attribute) {
+	Fill(x, y, w, Style{StyleOf(RoleNormal).Fg, bg})
+}
+
+// Fill fills w columns of row y, starting at col x, with blanks in style s.
+// Unlike Drawbar, attributes such as AttrReverse show.
+func Fill(x, y, w int, s Style) {
 	for i := x; i < x+w; i++ {
-		termbox.SetCell(i, y, ' ', termbox.ColorWhite, bg)
+		termbox.SetCell(i, y, ' ', s.Fg, s.Bg)
+	}
+}
+
+// Drawslider draws a vertical slider h rows tall in column x, with its top at
+// row y. The handle is drawn in fg, frac (between 0 and 1) of the way up.
+func Drawslider(x, y, h int, frac float64, fg termbox.Attribute) {
+	if h < 1 {
+		return
+	}
+	if frac < 0 {
+		frac = 0
+	} else if frac > 1 {
+		frac = 1
+	}
+	border := StyleOf(RoleBorder)
+	for i := 0; i < h; i++ {
+		termbox.SetCell(x, y+i, '│', border.Fg, border.Bg)
+	}
+	termbox.SetCell(x, y+h-1-int(frac*float64(h-1)+0.5), '█', fg, border.Bg)
+}
+
+// Block characters for drawing meters, in eighths of a cell
+var (
+	vblocks = []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+	hblocks = []rune{' ', '▏', '▎', '▍', '▌', '▋', '▊', '▉', '█'}
+)
+
+// Drawvmeter draws a vertical bar in column x rising from row y (the bottom)
+// up to h rows, filled to frac (between 0 and 1) of its height with eighth
+// of a cell precision.
+func Drawvmeter(x, y, h int, frac float64, fg termbox.Attribute) {
+	eighths, bg := meterEighths(h, frac), StyleOf(RoleNormal).Bg
+	for i := 0; i < h; i++ {
+		termbox.SetCell(x, y-i, vblocks[clampEighths(eighths-i*8)], fg, bg)
+	}
+}
+
+// Drawhmeter draws a horizontal bar in row y starting at column x, filled to
+// frac (between 0 and 1) of w columns with eighth of a cell precision.
+func Drawhmeter(x, y, w int, frac float64, fg termbox.Attribute) {
+	eighths, bg := meterEighths(w, frac), StyleOf(RoleNormal).Bg
+	for i := 0; i < w; i++ {
+		termbox.SetCell(x+i, y, hblocks[clampEighths(eighths-i*8)], fg, bg)
+	}
+}
+
+func meterEighths(cells int, frac float64) int {
+	if frac < 0 {
+		frac = 0
+	} else if frac > 1 {
+		frac = 1
+	}
+	return int(frac*float64(cells*8) + 0.5)
+}
+
+func clampEighths(n int) int {
+	if n < 0 {
+		return 0
+	} else if n > 8 {
+		return 8
 	}
+	return n
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// Spot asks the terminal to report when it gains and loses focus, which it
+// does by sending ESC [ I and ESC [ O. Unlike asking X which window is active,
+// this works wherever the terminal supports it, including on Wayland, in tmux
+// with focus-events on, and over ssh. termbox doesn't know the reports, so
+// input is read raw and they're picked out before termbox parses the rest.
+
+const (
+	focusReportingOn  = "\x1b[?1004h"
+	focusReportingOff = "\x1b[?1004l"
+)
+
+var (
+	focusInReport  = []byte("\x1b[I")
+	focusOutReport = []byte("\x1b[O")
+)
+
+// What the terminal last reported about its focus
+const (
+	focusUnreported int32 = iota
+	focusIn
+	focusOut
+)
+
+// terminalFocus is set by the event goroutine and read when notifying
+var terminalFocus int32 = focusUnreported
+
+// startFocusReporting asks the terminal to report its focus. Terminals which
+// can't ignore the request.
+func startFocusReporting() {
+	os.Stdout.WriteString(focusReportingOn)
+}
+
+func stopFocusReporting() {
+	os.Stdout.WriteString(focusReportingOff)
+}
+
+// parseInput splits raw input from the terminal into events with parse,
+// which is tb.ParseEvent outside of tests. Focus reports are noted in
+// terminalFocus rather than returned.
+func parseInput(data []byte, parse func([]byte) tb.Event) (events []tb.Event) {
+	for len(data) > 0 {
+		switch {
+		case bytes.HasPrefix(data, focusInReport):
+			atomic.StoreInt32(&terminalFocus, focusIn)
+			data = data[len(focusInReport):]
+			continue
+		case bytes.HasPrefix(data, focusOutReport):
+			atomic.StoreInt32(&terminalFocus, focusOut)
+			data = data[len(focusOutReport):]
+			continue
+		}
+		ev := parse(data)
+		if ev.N == 0 {
+			// Not something termbox understands, so skip a byte and try again
+			ev.N = 1
+		}
+		if ev.Type != tb.EventNone {
+			events = append(events, ev)
+		}
+		data = data[ev.N:]
+	}
+	return
+}
+
+// reportedFocus returns whether the terminal has focus, and whether it has
+// said either way
+func reportedFocus() (focused, reported bool) {
+	switch atomic.LoadInt32(&terminalFocus) {
+	case focusIn:
+		return true, true
+	case focusOut:
+		return false, true
+	}
+	return false, false
+}
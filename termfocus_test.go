@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+func TestParseInputFocus(t *testing.T) {
+	defer atomic.StoreInt32(&terminalFocus, focusUnreported)
+	tests := []struct {
+		input     string
+		wantchars string
+		wantfocus int32
+	}{
+		{"jk", "jk", focusUnreported},
+		{"\x1b[O", "", focusOut},
+		{"\x1b[I", "", focusIn},
+		{"j\x1b[Ok", "jk", focusOut},
+		{"\x1b[O\x1b[I[", "[", focusIn}, // [ goes back a screen, so mustn't be left over
+		{"日本", "日本", focusUnreported},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&terminalFocus, focusUnreported)
+		var chars []rune
+		for _, ev := range parseInput([]byte(test.input), tb.ParseEvent) {
+			chars = append(chars, ev.Ch)
+		}
+		if string(chars) != test.wantchars {
+			t.Errorf("parseInput(%q) gave %q, want %q", test.input, string(chars), test.wantchars)
+		}
+		if focus := atomic.LoadInt32(&terminalFocus); focus != test.wantfocus {
+			t.Errorf("parseInput(%q) left the focus %d, want %d", test.input, focus, test.wantfocus)
+		}
+	}
+}
+
+func TestTerminalFocusedReported(t *testing.T) {
+	defer atomic.StoreInt32(&terminalFocus, focusUnreported)
+	t.Setenv("WINDOWID", "")
+	if terminalFocused() {
+		t.Error("Focused before any report, outside X")
+	}
+	parseInput(focusInReport, tb.ParseEvent)
+	if !terminalFocused() {
+		t.Error("Not focused after the terminal reported gaining focus")
+	}
+	parseInput(focusOutReport, tb.ParseEvent)
+	if terminalFocused() {
+		t.Error("Focused after the terminal reported losing focus")
+	}
+}
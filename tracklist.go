@@ -0,0 +1,530 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+	sp "github.com/op/go-libspotify/spotify"
+	ui "github.com/wlcx/spot/termboxui"
+)
+
+// trackEntry is a track in a TrackList, along with what its playlist knows
+// about it
+type trackEntry struct {
+	track   *sp.Track
+	link    string // The track's link, kept for finding it cheaply
+	index   int    // Position in the playlist
+	addedby string
+	addedat time.Time
+	marked  bool // Picked out for a batch action
+}
+
+// trackColumn is a column a TrackList can show, with how to fill it in and
+// how to sort by it
+type trackColumn struct {
+	ui.Column
+	text func(e trackEntry) string
+	less func(a, b trackEntry) bool
+}
+
+// ArtistNames returns the names of all of a track's artists, comma separated
+func ArtistNames(track *sp.Track) string {
+	names := make([]string, track.Artists())
+	for i := range names {
+		names[i] = track.Artist(i).Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// AlbumName returns the name of a track's album, or "" if it hasn't got one
+func AlbumName(track *sp.Track) string {
+	if album := track.Album(); album != nil {
+		return album.Name()
+	}
+	return ""
+}
+
+// sameTrack returns whether a and b are the same track. libspotify hands out
+// a new *sp.Track each time a track is fetched, e.g. from a playlist or a
+// search, so they're compared by link.
+func sameTrack(a, b *sp.Track) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a == b || trackLink(a) == trackLink(b)
+}
+
+// trackLink returns track's link as a string, which is the same for every
+// *sp.Track of the same track
+func trackLink(track *sp.Track) string {
+	return track.Link().String()
+}
+
+func byText(text func(e trackEntry) string) func(a, b trackEntry) bool {
+	return func(a, b trackEntry) bool {
+		return strings.ToLower(text(a)) < strings.ToLower(text(b))
+	}
+}
+
+var (
+	titleText   = func(e trackEntry) string { return e.track.Name() }
+	artistsText = func(e trackEntry) string { return ArtistNames(e.track) }
+	albumText   = func(e trackEntry) string { return AlbumName(e.track) }
+	addedbyText = func(e trackEntry) string { return e.addedby }
+)
+
+// The columns a TrackList can show, by name
+var trackColumns = map[string]trackColumn{
+	"index": {
+		ui.Column{Title: "#", Width: 4, AlignRight: true},
+		func(e trackEntry) string { return strconv.Itoa(e.index + 1) },
+		func(a, b trackEntry) bool { return a.index < b.index },
+	},
+	"title": {
+		ui.Column{Title: "Title", Weight: 3, MinWidth: 12},
+		titleText,
+		byText(titleText),
+	},
+	"artists": {
+		ui.Column{Title: "Artists", Weight: 2, MinWidth: 10},
+		artistsText,
+		byText(artistsText),
+	},
+	"album": {
+		ui.Column{Title: "Album", Weight: 2, MinWidth: 10},
+		albumText,
+		byText(albumText),
+	},
+	"duration": {
+		ui.Column{Title: "Time", Width: 6, AlignRight: true},
+		func(e trackEntry) string { return PrettyDuration(e.track.Duration()) },
+		func(a, b trackEntry) bool { return a.track.Duration() < b.track.Duration() },
+	},
+	"popularity": {
+		ui.Column{Title: "Pop", Width: 4, AlignRight: true},
+		func(e trackEntry) string { return strconv.Itoa(e.track.Popularity()) },
+		func(a, b trackEntry) bool { return a.track.Popularity() < b.track.Popularity() },
+	},
+	"addedby": {
+		ui.Column{Title: "Added by", Weight: 1, MinWidth: 8},
+		addedbyText,
+		byText(addedbyText),
+	},
+	"addedat": {
+		ui.Column{Title: "Added", Width: 10},
+		func(e trackEntry) string {
+			if e.addedat.IsZero() {
+				return ""
+			}
+			return e.addedat.Format("2006-01-02")
+		},
+		func(a, b trackEntry) bool { return a.addedat.Before(b.addedat) },
+	},
+}
+
+// Columns shown in a TrackList unless configured otherwise
+var DefaultTrackColumns = []string{"index", "title", "artists", "album", "duration"}
+
+// TrackList shows a list of tracks, usually a playlist, as a table. The rows
+// can be sorted by any column without changing the playlist, so each row's
+// Data is the index of its entry in the playlist.
+type TrackList struct {
+	sl       ui.Table
+	entries  []trackEntry // In playlist order
+	columns  []string
+	sortby   string // Name of the column the rows are sorted by, if any
+	sortdesc bool
+	playlist *sp.Playlist // The playlist the tracks came from, if any
+}
+
+func NewTrackList(columns []string) *TrackList {
+	t := &TrackList{sl: ui.NewTable(nil)}
+	if len(columns) == 0 || t.SetColumns(columns) != nil {
+		t.SetColumns(DefaultTrackColumns)
+	}
+	return t
+}
+
+// SetColumns chooses which columns are shown, by name, in order
+func (t *TrackList) SetColumns(names []string) error {
+	var columns []ui.Column
+	for _, name := range names {
+		column, ok := trackColumns[name]
+		if !ok {
+			return fmt.Errorf("No such column %q", name)
+		}
+		columns = append(columns, column.Column)
+	}
+	t.columns = names
+	t.sl.Columns = columns
+	t.rebuild()
+	return nil
+}
+
+// TrackColumnNames returns the names of every column a TrackList can show
+func TrackColumnNames() []string {
+	var names []string
+	for name := range trackColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SortBy sorts the rows by the named column, or back into playlist order if
+// name is empty
+func (t *TrackList) SortBy(name string, desc bool) error {
+	if _, ok := trackColumns[name]; name != "" && !ok {
+		return fmt.Errorf("No such column %q", name)
+	}
+	t.sortby, t.sortdesc = name, desc
+	t.rebuild()
+	return nil
+}
+
+// CycleSort sorts by the next shown column along, or back into playlist
+// order after the last
+func (t *TrackList) CycleSort() {
+	next := 0
+	for i, name := range t.columns {
+		if name == t.sortby {
+			next = i + 1
+		}
+	}
+	if next < len(t.columns) {
+		t.SortBy(t.columns[next], t.sortdesc)
+	} else {
+		t.SortBy("", false)
+	}
+}
+
+// Sorted returns whether the rows are in a different order to the playlist
+func (t *TrackList) Sorted() bool {
+	return t.sortby != ""
+}
+
+func (t *TrackList) AddTrack(track *sp.Track) {
+	t.entries = append(t.entries, trackEntry{track: track, link: trackLink(track), index: len(t.entries)})
+	t.rebuild()
+}
+
+// SetPlaylist clears the tracklist and populates it with the contents of
+// playlist. Loading the playlist already shown again, e.g. Starred after a
+// track is starred, keeps the filter, and the selection on the same track.
+func (t *TrackList) SetPlaylist(playlist *sp.Playlist) {
+	if !samePlaylist(playlist, t.playlist) {
+		t.Clear()
+	}
+	selected := ""
+	if t.HasSelection() {
+		selected = t.entries[t.SelectedIndex()].link
+	}
+	t.entries = nil
+	t.playlist = playlist
+	for i := 0; i < playlist.Tracks(); i++ {
+		pt := playlist.Track(i)
+		track := pt.Track()
+		track.Wait()
+		entry := trackEntry{track: track, link: trackLink(track), index: i, addedat: pt.Time()}
+		if user := pt.User(); user != nil {
+			entry.addedby = user.Name()
+		}
+		t.entries = append(t.entries, entry)
+	}
+	t.rebuild()
+	for row, item := range t.sl.Items {
+		if selected != "" && t.entries[item.Data].link == selected {
+			t.sl.Selected = row
+		}
+	}
+}
+
+func (t *TrackList) Clear() {
+	t.sl.Clear()
+	t.entries = nil
+	t.playlist = nil
+}
+
+// Len returns the number of tracks in the list
+func (t *TrackList) Len() int {
+	return len(t.entries)
+}
+
+// Refresh redraws the rows, e.g. after a track is starred
+func (t *TrackList) Refresh() {
+	t.rebuild()
+}
+
+// rebuild regenerates the rows from the entries, in sorted order, keeping
+// the same entry selected
+func (t *TrackList) rebuild() {
+	selected := -1
+	if t.sl.Selected >= 0 && t.sl.Selected < len(t.sl.Items) {
+		selected = t.sl.Items[t.sl.Selected].Data
+	}
+	order := make([]int, len(t.entries))
+	for i := range order {
+		order[i] = i
+	}
+	if column, ok := trackColumns[t.sortby]; ok {
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := t.entries[order[i]], t.entries[order[j]]
+			if t.sortdesc {
+				return column.less(b, a)
+			}
+			return column.less(a, b)
+		})
+	}
+	t.sl.SortColumn = -1
+	for i, name := range t.columns {
+		if name == t.sortby {
+			t.sl.SortColumn = i
+			t.sl.SortDesc = t.sortdesc
+		}
+	}
+	items := make([]ui.ListItem, len(order))
+	for row, index := range order {
+		items[row] = t.item(index)
+		if index == selected {
+			t.sl.Selected = row
+		}
+	}
+	t.sl.SetItems(items)
+	if t.sl.Selected >= len(t.sl.Items) {
+		t.sl.Selected = len(t.sl.Items) - 1
+	}
+	if t.sl.Selected < 0 {
+		t.sl.Selected = 0
+	}
+}
+
+func (t *TrackList) item(index int) ui.ListItem {
+	entry := t.entries[index]
+	marker := " "
+	if entry.track.IsStarred() {
+		marker = "*"
+	}
+	cells := make([]string, len(t.columns))
+	for i, name := range t.columns {
+		cells[i] = trackColumns[name].text(entry)
+	}
+	if len(cells) > 0 {
+		cells[0] = marker + cells[0]
+	}
+	return ui.ListItem{
+		Cells:    cells,
+		Data:     index,
+		Marked:   entry.marked,
+		Disabled: entry.track.Availability() != sp.TrackAvailabilityAvailable, // Track not playable
+	}
+}
+
+// reindex renumbers the entries after they've been added, removed or moved
+func (t *TrackList) reindex() {
+	for i := range t.entries {
+		t.entries[i].index = i
+	}
+	t.rebuild()
+}
+
+// Insert adds track to the playlist at position i, and to the list
+func (t *TrackList) Insert(i int, track *sp.Track) error {
+	if err := t.playlist.AddTracks(i, track); err != nil {
+		return err
+	}
+	entry := trackEntry{track: track, link: trackLink(track), addedat: time.Now()}
+	t.entries = append(t.entries[:i], append([]trackEntry{entry}, t.entries[i:]...)...)
+	t.reindex()
+	return nil
+}
+
+// Remove takes track i out of the playlist and the list
+func (t *TrackList) Remove(i int) error {
+	if err := t.playlist.RemoveTracks(i); err != nil {
+		return err
+	}
+	t.entries = append(t.entries[:i], t.entries[i+1:]...)
+	t.reindex()
+	return nil
+}
+
+// Move moves track i of the playlist to position j
+func (t *TrackList) Move(i, j int) error {
+	if err := t.playlist.ReorderTracks([]int{i}, reorderPosition(i, j)); err != nil {
+		return err
+	}
+	entry := t.entries[i]
+	t.entries = append(t.entries[:i], t.entries[i+1:]...)
+	t.entries = append(t.entries[:j], append([]trackEntry{entry}, t.entries[j:]...)...)
+	// Keep the selection on the moved track
+	if t.sl.Selected >= 0 && t.sl.Selected < len(t.sl.Items) && t.sl.Items[t.sl.Selected].Data == i {
+		t.sl.Items[t.sl.Selected].Data = j
+	}
+	t.reindex()
+	return nil
+}
+
+func (t *TrackList) Draw(x, y, w, h int, focussed bool) {
+	// Highlight whatever is playing. This happens on every redraw, so the
+	// playing track's link is worked out once, and the entries' are kept.
+	t.sl.Highlit = -1
+	if spot.Player.track != nil {
+		playing := trackLink(spot.Player.track)
+		for row, item := range t.sl.Items {
+			if t.entries[item.Data].link == playing {
+				t.sl.Highlit = row
+			}
+		}
+	}
+	t.sl.Draw(x, y, w, h, focussed)
+}
+
+func (t *TrackList) SelectUp() {
+	t.sl.SelectUp()
+}
+
+func (t *TrackList) SelectDown() {
+	t.sl.SelectDown()
+}
+
+func (t *TrackList) GetSelected() *sp.Track {
+	return t.entries[t.SelectedIndex()].track
+}
+
+// HasSelection returns whether a track is selected and shown, which it isn't
+// when the list is empty or the filter matches nothing
+func (t *TrackList) HasSelection() bool {
+	return t.Len() > 0 && t.sl.SelectionShown()
+}
+
+// SelectedIndex returns the playlist position of the selected track. Check
+// HasSelection first.
+func (t *TrackList) SelectedIndex() int {
+	return t.sl.Items[t.sl.Selected].Data
+}
+
+// keys are the keys which work the same in any track list, besides
+// navigation and marking: sorting, and acting on the marked or selected
+// tracks
+func (t *TrackList) keys() []keybinding {
+	// Acting on no tracks would act on whatever is playing instead
+	withTracks := func(run func(g *Spot)) func(g *Spot) {
+		return func(g *Spot) {
+			if t.Len() > 0 {
+				run(g)
+			}
+		}
+	}
+	return []keybinding{
+		{ch: 's', name: "s", help: "Sort by the next column, reverse the sort", run: func(*Spot) {
+			t.CycleSort()
+		}},
+		{ch: 'S', name: "S", help: "Sort by the next column, reverse the sort", run: func(*Spot) {
+			t.SortBy(t.sortby, !t.sortdesc)
+		}},
+		{ch: 'e', name: "e", help: "Queue the marked or selected tracks", run: withTracks(func(g *Spot) {
+			g.status(g.enqueue())
+		})},
+		{ch: 'y', name: "y", help: "Copy links to the marked or selected tracks", run: withTracks(func(g *Spot) {
+			g.status(g.copylinks())
+		})},
+		{ch: 'a', name: "a", help: "Add the marked or selected tracks to a playlist", run: withTracks(func(g *Spot) {
+			// Start a command for the user to finish with a playlist name
+			g.StartCommand("add ")
+		})},
+		{ch: 'o', name: "o", help: "Open the album, artist", run: func(g *Spot) {
+			if t.HasSelection() {
+				g.status(g.OpenAlbum(t.GetSelected().Album()))
+			}
+		}},
+		{ch: 'O', name: "O", help: "Open the album, artist", run: func(g *Spot) {
+			if !t.HasSelection() {
+				return
+			}
+			if track := t.GetSelected(); track.Artists() > 0 {
+				g.status(g.OpenArtist(track.Artist(0)))
+			}
+		}},
+	}
+}
+
+// Keys lists the keys HandleKey handles
+func (t *TrackList) Keys() []ui.KeyHelp {
+	keys := keyHelp(t.keys())
+	keys = append(keys, ui.ListKeys...)
+	return append(keys, ui.MarkKeys...)
+}
+
+// HandleKey handles the keys which work the same in any track list:
+// navigation, marking, sorting, and acting on the marked or selected tracks.
+// It returns whether ev was one of them.
+func (t *TrackList) HandleKey(ev tb.Event) bool {
+	return t.sl.HandleKey(ev) || t.HandleMarkKey(ev) || spot.runKey(t.keys(), ev)
+}
+
+// PlaySelected plays from the selected track on, in the order shown
+func (t *TrackList) PlaySelected() error {
+	if !t.HasSelection() {
+		return nil
+	}
+	return spot.Player.PlayFrom(t.TracksFrom(t.sl.Selected))
+}
+
+// HandleMarkKey handles the list's keys for marking tracks, returning whether
+// ev was one of them
+func (t *TrackList) HandleMarkKey(ev tb.Event) bool {
+	if !t.sl.HandleMarkKey(ev) {
+		return false
+	}
+	t.saveMarks()
+	return true
+}
+
+// saveMarks copies the rows' marks to their entries, so they survive the rows
+// being rebuilt
+func (t *TrackList) saveMarks() {
+	for _, item := range t.sl.Items {
+		t.entries[item.Data].marked = item.Marked
+	}
+}
+
+// ClearMarks unmarks every track
+func (t *TrackList) ClearMarks() {
+	t.sl.ClearMarks()
+	t.saveMarks()
+}
+
+// Targets returns the playlist positions of the marked tracks, in the order
+// shown, or of the selected track if none are marked
+func (t *TrackList) Targets() (indexes []int) {
+	for _, i := range t.sl.MarkedItems() {
+		indexes = append(indexes, t.sl.Items[i].Data)
+	}
+	if len(indexes) == 0 && t.HasSelection() {
+		indexes = []int{t.SelectedIndex()}
+	}
+	return
+}
+
+// TargetTracks returns the tracks at Targets
+func (t *TrackList) TargetTracks() (tracks []*sp.Track) {
+	for _, i := range t.Targets() {
+		tracks = append(tracks, t.entries[i].track)
+	}
+	return
+}
+
+// TracksFrom returns the playable tracks from row onwards, in the order
+// they are shown
+func (t *TrackList) TracksFrom(row int) (tracks []*sp.Track) {
+	for _, item := range t.sl.Items[row:] {
+		if !item.Disabled {
+			tracks = append(tracks, t.entries[item.Data].track)
+		}
+	}
+	return
+}